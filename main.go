@@ -6,6 +6,7 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
@@ -22,7 +23,14 @@ import (
 func main() {
 	zerolog.TimeFieldFormat = time.RFC3339Nano
 
-	cfg, err := config.Load()
+	opts, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	cfg, err := config.LoadWith(opts)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to load configuration")
 	}
@@ -32,34 +40,239 @@ func main() {
 		log.Fatal().Err(err).Str("log_level", cfg.LogLevel).Msg("invalid log level")
 	}
 	log.Logger = log.Level(level)
+	for _, warning := range cfg.Warnings {
+		log.Warn().Msg(warning)
+	}
+
+	if cfg.Transport == config.TransportStdio {
+		runStdio(cfg, opts)
+		return
+	}
 
 	proxyHandler, err := proxy.New(cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to construct proxy")
 	}
 
+	tlsConfig, err := proxy.ServerTLSConfig(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to configure listener TLS")
+	}
+
 	server := &http.Server{
 		Addr:         cfg.ListenAddr,
 		Handler:      proxyHandler,
 		ReadTimeout:  cfg.ServerReadTimeout,
 		WriteTimeout: cfg.ServerWriteTimeout,
 		IdleTimeout:  cfg.ServerIdleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+	probeCtx, stopProbe := context.WithCancel(context.Background())
+	defer stopProbe()
+
+	servers := []*http.Server{server}
+	if p, ok := proxyHandler.(*proxy.Proxy); ok {
+		server.ConnState = p.ConnState
+		go p.RunReadinessProbe(probeCtx)
+		go watchReloads(probeCtx, p, cfg, opts)
+		if cfg.AdminAddr != "" {
+			servers = append(servers, startAdminServer(cfg, p))
+		}
+	}
+
+	for _, route := range cfg.Routes {
+		log.Info().
+			Str("route", route.Name).
+			Str("prefix", route.Prefix).
+			Str("upstream", route.Config.Upstream.String()).
+			Msg("mounted upstream route")
 	}
 
 	go func() {
 		log.Info().
 			Str("listen_addr", cfg.ListenAddr).
 			Str("upstream", cfg.Upstream.String()).
+			Bool("tls", tlsConfig != nil).
 			Msg("starting MCP auth proxy")
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal().Err(err).Msg("proxy server exited unexpectedly")
 		}
 	}()
 
-	waitForShutdown(context.Background(), server, cfg.GracefulShutdownTimeout)
+	waitForShutdown(context.Background(), proxyHandler, cfg.GracefulShutdownTimeout, servers...)
+}
+
+// flagSettings maps command line flags to the settings they override.
+var flagSettings = []struct {
+	name, key, usage string
+}{
+	{name: "listen-addr", key: "MCP_LISTEN_ADDR", usage: "local listen address"},
+	{name: "upstream-url", key: "MCP_UPSTREAM_URL", usage: "default upstream MCP server URL"},
+	{name: "transport", key: "MCP_TRANSPORT", usage: "client transport: http or stdio"},
+	{name: "log-level", key: "MCP_LOG_LEVEL", usage: "log level"},
+	{name: "admin-addr", key: "MCP_ADMIN_ADDR", usage: "admin listener address"},
 }
 
-func waitForShutdown(ctx context.Context, srv *http.Server, timeout time.Duration) {
+// parseFlags reads the command line into load options. Only flags that were
+// set override the environment; the rest leave it untouched.
+func parseFlags(args []string) (config.Options, error) {
+	fs := flag.NewFlagSet("mcp-auth-proxy", flag.ContinueOnError)
+	file := fs.String("config", "", "YAML, JSON or TOML config file (default $MCP_CONFIG_FILE)")
+	keys := make(map[string]string, len(flagSettings))
+	for _, setting := range flagSettings {
+		fs.String(setting.name, "", setting.usage+" (overrides $"+setting.key+")")
+		keys[setting.name] = setting.key
+	}
+	if err := fs.Parse(args); err != nil {
+		return config.Options{}, err
+	}
+
+	opts := config.Options{File: *file, Flags: make(map[string]string)}
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			opts.Flags[key] = f.Value.String()
+		}
+	})
+	return opts, nil
+}
+
+// startAdminServer serves the proxy's operational endpoints on the admin
+// address in the background.
+func startAdminServer(cfg config.Config, p *proxy.Proxy) *http.Server {
+	admin := &http.Server{
+		Addr:              cfg.AdminAddr,
+		Handler:           p.AdminHandler(),
+		ReadHeaderTimeout: cfg.ServerReadTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+	}
+
+	go func() {
+		log.Info().Str("admin_addr", cfg.AdminAddr).Msg("starting admin listener")
+		if err := admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("admin server exited unexpectedly")
+		}
+	}()
+
+	return admin
+}
+
+// reloader applies a freshly loaded configuration; both the proxy and the
+// stdio bridge implement it.
+type reloader interface {
+	Reload(load func() (config.Config, error)) error
+}
+
+// watchReloads reloads the configuration on SIGHUP and, when
+// MCP_CONFIG_WATCH_INTERVAL is set, whenever the config file, the tool
+// policy file or the inbound tokens file changes. Those files and the
+// interval are taken from the running configuration, so after a reload that
+// points at other files, or changes the interval, the new ones are watched.
+// When MCP_SECRET_REFRESH_INTERVAL is set it also resolves secret references
+// again on that interval and reloads when a resolved value differs from the
+// running one. A failed reload is logged by Reload and leaves the running
+// configuration in place.
+func watchReloads(ctx context.Context, r reloader, cfg config.Config, opts config.Options) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	changed := make(chan struct{}, 1)
+	stopWatch := watchFiles(ctx, cfg, changed)
+	defer func() { stopWatch() }()
+
+	var refresh <-chan time.Time
+	if cfg.SecretRefreshInterval > 0 {
+		ticker := time.NewTicker(cfg.SecretRefreshInterval)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	// running is the configuration last applied; its secrets digest tells
+	// whether a refresh changed anything.
+	running := cfg
+	for {
+		load := func() (config.Config, error) { return config.LoadWith(opts) }
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Info().Msg("received SIGHUP; reloading configuration")
+		case <-changed:
+			log.Info().Msg("configuration files changed; reloading configuration")
+		case <-refresh:
+			next, err := config.LoadWith(opts)
+			if err == nil && next.SecretsDigest == running.SecretsDigest {
+				continue
+			}
+			if err == nil {
+				log.Info().Msg("secrets changed; reloading configuration")
+			}
+			load = func() (config.Config, error) { return next, err }
+		}
+
+		var loaded config.Config
+		err := r.Reload(func() (config.Config, error) {
+			var err error
+			loaded, err = load()
+			return loaded, err
+		})
+		if err != nil {
+			continue
+		}
+		if loaded.ConfigWatchInterval != running.ConfigWatchInterval || watchedFiles(loaded) != watchedFiles(running) {
+			stopWatch()
+			stopWatch = watchFiles(ctx, loaded, changed)
+		}
+		running = loaded
+	}
+}
+
+// watchFiles polls the files cfg reloads from every ConfigWatchInterval,
+// until the returned function is called or ctx ends. It polls nothing when
+// the interval is zero.
+func watchFiles(ctx context.Context, cfg config.Config, changed chan<- struct{}) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if cfg.ConfigWatchInterval > 0 {
+		files := watchedFiles(cfg)
+		go config.Watch(ctx, cfg.ConfigWatchInterval, changed, files[:]...)
+	}
+	return cancel
+}
+
+// watchedFiles lists the config, tool policy and inbound tokens files of cfg.
+func watchedFiles(cfg config.Config) [3]string {
+	return [3]string{cfg.ConfigFile, cfg.ToolPolicyFile, cfg.InboundTokensFile}
+}
+
+// runStdio bridges stdin/stdout to the upstream until stdin closes or a
+// termination signal arrives. Logs continue to go to stderr.
+func runStdio(cfg config.Config, opts config.Options) {
+	bridge, err := proxy.NewStdioBridge(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to construct stdio bridge")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go watchReloads(ctx, bridge, cfg, opts)
+
+	log.Info().
+		Str("upstream", cfg.Upstream.String()).
+		Msg("starting MCP auth proxy in stdio mode")
+	if err := bridge.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		log.Fatal().Err(err).Msg("stdio bridge exited unexpectedly")
+	}
+}
+
+// waitForShutdown blocks until a termination signal arrives, drains the
+// servers, and then ends any MCP sessions the proxy still holds upstream.
+func waitForShutdown(ctx context.Context, handler http.Handler, timeout time.Duration, servers ...*http.Server) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
@@ -70,12 +283,21 @@ func waitForShutdown(ctx context.Context, srv *http.Server, timeout time.Duratio
 	shutdownCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Error().Err(err).Msg("graceful shutdown failed; forcing close")
-		if closeErr := srv.Close(); closeErr != nil {
-			log.Error().Err(closeErr).Msg("forced close failed")
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Str("addr", srv.Addr).Msg("graceful shutdown failed; forcing close")
+			if closeErr := srv.Close(); closeErr != nil {
+				log.Error().Err(closeErr).Msg("forced close failed")
+			}
 		}
 	}
 
+	if p, ok := handler.(*proxy.Proxy); ok {
+		// Give session teardown its own budget so a slow drain cannot starve it.
+		sessionCtx, cancelSessions := context.WithTimeout(ctx, timeout)
+		defer cancelSessions()
+		p.Shutdown(sessionCtx)
+	}
+
 	log.Info().Msg("proxy stopped")
 }
@@ -0,0 +1,33 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package auth implements the strategies used to authenticate outbound
+// requests against upstream MCP servers.
+package auth
+
+import (
+	"net/http"
+)
+
+const (
+	// ModeHMAC signs requests with the auth-gateway HMAC headers.
+	ModeHMAC = "hmac"
+	// ModeBearer attaches a static bearer token.
+	ModeBearer = "bearer"
+	// ModeOAuth2 mints bearer tokens through the client-credentials grant.
+	ModeOAuth2 = "oauth2"
+	// ModeSigV4 signs requests with AWS Signature Version 4.
+	ModeSigV4 = "sigv4"
+)
+
+// RequestAuthenticator decorates an outbound request with upstream
+// credentials. body is the buffered request payload for schemes that sign it;
+// implementations must not modify it.
+type RequestAuthenticator interface {
+	Authenticate(req *http.Request, body []byte) error
+}
+
+// Authenticate implements RequestAuthenticator for the HMAC signer.
+func (s *Signer) Authenticate(req *http.Request, body []byte) error {
+	return s.AttachSignatureWithBody(req, body)
+}
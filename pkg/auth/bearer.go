@@ -0,0 +1,28 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StaticToken attaches a fixed bearer token to every request.
+type StaticToken struct {
+	Token string
+}
+
+// NewStaticToken constructs a bearer authenticator for the provided token.
+func NewStaticToken(token string) *StaticToken {
+	return &StaticToken{Token: token}
+}
+
+// Authenticate sets the Authorization header, replacing any client value.
+func (t *StaticToken) Authenticate(req *http.Request, _ []byte) error {
+	if t.Token == "" {
+		return fmt.Errorf("bearer token must be set")
+	}
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	return nil
+}
@@ -0,0 +1,233 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshSkew is how long before expiry a token is refreshed.
+	DefaultRefreshSkew = 30 * time.Second
+	// defaultFetchTimeout bounds token requests when the client sets no timeout.
+	defaultFetchTimeout = 30 * time.Second
+)
+
+// TokenInvalidator is implemented by authenticators whose credentials can be
+// rejected by the upstream and minted again, such as OAuth2 access tokens.
+type TokenInvalidator interface {
+	// Invalidate discards the credential attached to req so the next
+	// Authenticate call obtains a fresh one.
+	Invalidate(req *http.Request)
+}
+
+// ClientCredentials mints and caches bearer tokens using the OAuth2
+// client-credentials grant. Tokens are reused until RefreshSkew before they
+// expire; inside that window the current token is still served while a
+// single background fetch obtains its replacement. Concurrent callers that
+// find no usable token wait on the same fetch instead of each calling the
+// token endpoint.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Audience is sent as the "audience" parameter when set, as required by
+	// providers such as Auth0.
+	Audience string
+	// RefreshSkew is the window before expiry in which tokens are refreshed.
+	RefreshSkew time.Duration
+	// Client performs token requests; http.DefaultClient is used when nil.
+	Client *http.Client
+	Now    func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	// inflight is non-nil while a fetch is running; waiters block on done.
+	inflight *tokenFetch
+}
+
+// tokenFetch is a token request shared by every caller waiting on it.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewClientCredentials constructs a token source for the given endpoint and
+// client credentials.
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes []string, client *http.Client) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		RefreshSkew:  DefaultRefreshSkew,
+		Client:       client,
+		Now:          time.Now,
+	}
+}
+
+// Authenticate attaches a cached or freshly minted access token.
+func (c *ClientCredentials) Authenticate(req *http.Request, _ []byte) error {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token if it is the one attached to req, so a
+// request rejected with 401 can be retried with a new token.
+func (c *ClientCredentials) Invalidate(req *http.Request) {
+	rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rejected != "" && rejected == c.token {
+		c.token = ""
+		c.expires = time.Time{}
+	}
+}
+
+// Token returns a usable access token. A token inside the refresh window is
+// returned immediately while a background fetch replaces it; a missing or
+// expired token blocks until the shared fetch completes or ctx ends.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	now := c.Now()
+	if c.token != "" && (c.expires.IsZero() || now.Before(c.expires)) {
+		token := c.token
+		if !c.expires.IsZero() && !now.Add(c.RefreshSkew).Before(c.expires) {
+			c.startFetchLocked()
+		}
+		c.mu.Unlock()
+		return token, nil
+	}
+	fetch := c.startFetchLocked()
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.token, fetch.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// startFetchLocked returns the in-flight fetch, starting one if needed. The
+// caller must hold c.mu.
+func (c *ClientCredentials) startFetchLocked() *tokenFetch {
+	if c.inflight != nil {
+		return c.inflight
+	}
+
+	fetch := &tokenFetch{done: make(chan struct{})}
+	c.inflight = fetch
+
+	go func() {
+		// The fetch outlives any single caller, so it is not bound to a
+		// request context.
+		ctx := context.Background()
+		if c.Client == nil || c.Client.Timeout == 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, defaultFetchTimeout)
+			defer cancel()
+		}
+
+		token, expires, err := c.fetch(ctx)
+
+		c.mu.Lock()
+		if err == nil {
+			c.token = token
+			c.expires = expires
+		}
+		c.inflight = nil
+		c.mu.Unlock()
+
+		fetch.token, fetch.err = token, err
+		close(fetch.done)
+	}()
+
+	return fetch
+}
+
+// tokenResponse is the subset of RFC 6749 section 5.1 the proxy relies on.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetch performs the client-credentials exchange against the token endpoint.
+func (c *ClientCredentials) fetch(ctx context.Context) (string, time.Time, error) {
+	if c.TokenURL == "" || c.ClientID == "" || c.ClientSecret == "" {
+		return "", time.Time{}, fmt.Errorf("token url, client id and client secret must be set")
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	issued := c.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("request token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	const maxTokenBody = 1 << 20
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenBody))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed tokenResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", time.Time{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if parsed.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token endpoint returned an empty access token")
+	}
+	if parsed.TokenType != "" && !strings.EqualFold(parsed.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("unsupported token type %q", parsed.TokenType)
+	}
+
+	// Tokens without expires_in carry a zero expiry and are kept indefinitely.
+	var expires time.Time
+	if parsed.ExpiresIn > 0 {
+		expires = issued.Add(time.Duration(parsed.ExpiresIn) * time.Second)
+	}
+	return parsed.AccessToken, expires, nil
+}
@@ -0,0 +1,211 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCredentialsCachesToken(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("unexpected grant type %q", got)
+		}
+		if got := r.PostForm.Get("scope"); got != "mcp:read mcp:write" {
+			t.Errorf("unexpected scope %q", got)
+		}
+		if got := r.PostForm.Get("audience"); got != "https://upstream.example.com" {
+			t.Errorf("unexpected audience %q", got)
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			t.Errorf("unexpected client credentials %q/%q", id, secret)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":60}`, n)
+	}))
+	defer server.Close()
+
+	clock := newTestClock(time.Unix(1_700_000_000, 0))
+	source := NewClientCredentials(server.URL, "client", "secret", []string{"mcp:read", "mcp:write"}, server.Client())
+	source.Audience = "https://upstream.example.com"
+	source.Now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if token != "token-1" {
+			t.Fatalf("expected cached token, got %q", token)
+		}
+	}
+
+	// Move past expiry so the next call blocks on a fresh token.
+	clock.Advance(61 * time.Second)
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token != "token-2" {
+		t.Fatalf("expected refreshed token, got %q", token)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 token requests, got %d", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://upstream.example.com/mcp", nil)
+	if err := source.Authenticate(req, nil); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token-2" {
+		t.Fatalf("unexpected authorization header %q", got)
+	}
+
+	source.Invalidate(req)
+	if token, err := source.Token(context.Background()); err != nil || token != "token-3" {
+		t.Fatalf("expected token-3 after invalidation, got %q (%v)", token, err)
+	}
+}
+
+func TestClientCredentialsRefreshesInBackground(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			<-release
+		}
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":60}`, n)
+	}))
+	defer server.Close()
+
+	clock := newTestClock(time.Unix(1_700_000_000, 0))
+	source := NewClientCredentials(server.URL, "client", "secret", nil, server.Client())
+	source.Now = clock.Now
+
+	if _, err := source.Token(context.Background()); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	// Inside the refresh window the current token is served without waiting
+	// while concurrent callers share a single background fetch.
+	clock.Advance(45 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.Token(context.Background())
+			if err != nil || token != "token-1" {
+				t.Errorf("expected current token during refresh, got %q (%v)", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if token == "token-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not complete")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected a single background fetch, got %d token requests", got)
+	}
+}
+
+func TestClientCredentialsSharesBlockingFetch(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = fmt.Fprint(w, `{"access_token":"shared","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	source := NewClientCredentials(server.URL, "client", "secret", nil, server.Client())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := source.Token(context.Background()); err != nil || token != "shared" {
+				t.Errorf("unexpected token %q (%v)", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected concurrent callers to share one fetch, got %d", got)
+	}
+}
+
+func TestClientCredentialsSurfacesEndpointErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	source := NewClientCredentials(server.URL, "client", "wrong", nil, server.Client())
+	if _, err := source.Token(context.Background()); err == nil {
+		t.Fatal("expected error from token endpoint")
+	}
+}
+
+func TestStaticTokenAuthenticate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://upstream.example.com/mcp", nil)
+	req.Header.Set("Authorization", "Bearer client-supplied")
+
+	if err := NewStaticToken("static").Authenticate(req, nil); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer static" {
+		t.Fatalf("unexpected authorization header %q", got)
+	}
+}
+
+// testClock is a goroutine-safe adjustable clock.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock(now time.Time) *testClock {
+	return &testClock{now: now}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
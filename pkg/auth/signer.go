@@ -9,6 +9,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,6 +19,22 @@ const (
 	HeaderAPIKey    = "x-api-key-id"
 	HeaderSignature = "x-signature"
 	HeaderTimestamp = "x-timestamp"
+	// HeaderSignatureVersion advertises the signing scheme so the gateway can
+	// tell v1 and v2 signatures apart. It is omitted for v1.
+	HeaderSignatureVersion = "x-signature-version"
+	// HeaderContentSHA256 carries the hex SHA-256 digest of the request body.
+	HeaderContentSHA256 = "x-content-sha256"
+	// HeaderSignedHeaders lists the lower-cased header names covered by a v2
+	// signature, separated by semicolons.
+	HeaderSignedHeaders = "x-signed-headers"
+)
+
+const (
+	// SignatureV1 signs only the method, path and timestamp.
+	SignatureV1 = "v1"
+	// SignatureV2 additionally covers the canonical query string, a selectable
+	// set of headers and the SHA-256 digest of the body.
+	SignatureV2 = "v2"
 )
 
 // Signer injects HMAC auth headers compatible with the upstream gateway.
@@ -24,6 +42,11 @@ type Signer struct {
 	Key    string
 	Secret string
 	Now    func() time.Time
+	// Version selects the signing scheme; empty behaves like SignatureV1.
+	Version string
+	// SignedHeaders names the headers folded into v2 signatures. "host" refers
+	// to the request host rather than a header field.
+	SignedHeaders []string
 }
 
 // NewSigner constructs a signer with the provided key/secret and sane defaults.
@@ -34,23 +57,54 @@ func NewSigner(key, secret string) *Signer {
 		Now: func() time.Time {
 			return time.Now().UTC()
 		},
+		Version: SignatureV1,
 	}
 }
 
 // AttachSignature mutates the request by injecting auth headers computed from the method,
-// target path, and timestamp.
+// target path, and timestamp. With SignatureV2 the body is treated as empty;
+// use AttachSignatureWithBody when the payload is available.
 func (s *Signer) AttachSignature(req *http.Request) error {
+	return s.AttachSignatureWithBody(req, nil)
+}
+
+// AttachSignatureWithBody signs the request using the configured version.
+// The body is only consulted by SignatureV2, which binds its digest into the
+// signature so a captured request cannot be replayed with another payload.
+func (s *Signer) AttachSignatureWithBody(req *http.Request, body []byte) error {
 	if s.Key == "" || s.Secret == "" {
 		return fmt.Errorf("signer key and secret must be set")
 	}
 
 	timestamp := s.Now().Format(time.RFC3339)
 
-	payload := strings.Join([]string{
-		req.Method,
-		req.URL.Path,
-		timestamp,
-	}, "\n")
+	var payload string
+	switch s.Version {
+	case "", SignatureV1:
+		payload = strings.Join([]string{
+			req.Method,
+			req.URL.Path,
+			timestamp,
+		}, "\n")
+	case SignatureV2:
+		digest := BodyDigest(body)
+		names := canonicalHeaderNames(s.SignedHeaders)
+		payload = strings.Join([]string{
+			SignatureV2,
+			req.Method,
+			req.URL.Path,
+			canonicalQuery(req.URL),
+			canonicalHeaders(req, names),
+			strings.Join(names, ";"),
+			timestamp,
+			digest,
+		}, "\n")
+		req.Header.Set(HeaderSignatureVersion, SignatureV2)
+		req.Header.Set(HeaderContentSHA256, digest)
+		req.Header.Set(HeaderSignedHeaders, strings.Join(names, ";"))
+	default:
+		return fmt.Errorf("unsupported signature version %q", s.Version)
+	}
 
 	mac := hmac.New(sha256.New, []byte(s.Secret))
 	if _, err := mac.Write([]byte(payload)); err != nil {
@@ -66,3 +120,73 @@ func (s *Signer) AttachSignature(req *http.Request) error {
 
 	return nil
 }
+
+// BodyDigest returns the lower-case hex SHA-256 digest of body.
+func BodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalQuery renders the query string with keys and values sorted and
+// query-escaped so parameter order does not affect the signature.
+func canonicalQuery(u *url.URL) string {
+	values := u.Query()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(values))
+	for _, k := range keys {
+		vals := append([]string(nil), values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaderNames lower-cases, de-duplicates and sorts header names.
+func canonicalHeaderNames(names []string) []string {
+	seen := make(map[string]struct{}, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// canonicalHeaders renders one "name:value" line per signed header. Missing
+// headers are signed as empty so they cannot be added in transit.
+func canonicalHeaders(req *http.Request, names []string) string {
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		var value string
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		} else {
+			vals := req.Header.Values(name)
+			trimmed := make([]string, 0, len(vals))
+			for _, v := range vals {
+				trimmed = append(trimmed, strings.TrimSpace(v))
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		lines = append(lines, name+":"+value)
+	}
+	return strings.Join(lines, "\n")
+}
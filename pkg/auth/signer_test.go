@@ -50,3 +50,71 @@ func TestSignerAttachSignature(t *testing.T) {
 		}
 	}
 }
+
+func TestSignerAttachSignatureV2(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+
+	newRequest := func() *http.Request {
+		u, err := url.Parse("https://example.com/v1/test?foo=bar&a=2&a=1")
+		if err != nil {
+			t.Fatalf("failed to parse url: %v", err)
+		}
+		req := &http.Request{
+			Method: "POST",
+			URL:    u,
+			Host:   u.Host,
+			Header: make(http.Header),
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	signer := NewSigner("key123", "secret456")
+	signer.Version = SignatureV2
+	signer.SignedHeaders = []string{"Content-Type", "host", "content-type"}
+	signer.Now = func() time.Time {
+		return time.Unix(1_700_000_000, 0).UTC()
+	}
+
+	req := newRequest()
+	if err := signer.AttachSignatureWithBody(req, body); err != nil {
+		t.Fatalf("AttachSignatureWithBody: %v", err)
+	}
+
+	want := map[string]string{
+		HeaderAPIKey:           "key123",
+		HeaderSignature:        "971f180301a22e82234100bb389386463a144c85c038dfbea7ca43d2dd5acab3",
+		HeaderTimestamp:        "2023-11-14T22:13:20Z",
+		HeaderSignatureVersion: SignatureV2,
+		HeaderContentSHA256:    "98e0961a7c1232f08d2f2187d13c4a1a22a0641e00e5dec0eca645d646077fab",
+		HeaderSignedHeaders:    "content-type;host",
+	}
+	for k, v := range want {
+		if got := req.Header.Get(k); got != v {
+			t.Errorf("%s header mismatch: got %q, want %q", k, got, v)
+		}
+	}
+
+	tampered := newRequest()
+	if err := signer.AttachSignatureWithBody(tampered, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)); err != nil {
+		t.Fatalf("AttachSignatureWithBody: %v", err)
+	}
+	if tampered.Header.Get(HeaderSignature) == req.Header.Get(HeaderSignature) {
+		t.Fatal("signature should change when the body changes")
+	}
+}
+
+func TestSignerRejectsUnknownVersion(t *testing.T) {
+	u, err := url.Parse("https://example.com/v1/test")
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	signer := NewSigner("key123", "secret456")
+	signer.Version = "v9"
+
+	req := &http.Request{Method: "GET", URL: u, Header: make(http.Header)}
+	if err := signer.AttachSignature(req); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestSigV4SignerVanillaVector checks the "get-vanilla" case from the AWS
+// SigV4 test suite.
+func TestSigV4SignerVanillaVector(t *testing.T) {
+	u, err := url.Parse("https://example.amazonaws.com/")
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	req := &http.Request{
+		Method: "GET",
+		URL:    u,
+		Host:   u.Host,
+		Header: make(http.Header),
+	}
+
+	signer := NewSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", "us-east-1", "service")
+	signer.Now = func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	}
+
+	if err := signer.Authenticate(req, nil); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("authorization mismatch:\n got %s\nwant %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Fatalf("unexpected x-amz-date: %q", got)
+	}
+}
+
+func TestSigV4SignerRequiresScope(t *testing.T) {
+	u, err := url.Parse("https://example.amazonaws.com/")
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	signer := NewSigV4Signer("AKIDEXAMPLE", "secret", "", "", "service")
+	req := &http.Request{Method: "GET", URL: u, Header: make(http.Header)}
+	if err := signer.Authenticate(req, nil); err == nil {
+		t.Fatal("expected error when region is missing")
+	}
+}
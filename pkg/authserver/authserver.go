@@ -0,0 +1,678 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package authserver implements a minimal OAuth 2.1 authorization server so
+// spec-compliant MCP clients can obtain tokens for the local proxy. It serves
+// RFC 8414 authorization-server metadata, RFC 9728 protected-resource
+// metadata, RFC 7591 dynamic client registration, and the
+// authorization-code-with-PKCE and refresh-token grants. State is kept in
+// memory; clients re-register after a restart. Registration and
+// authorization are open unless Options.Approve gates them.
+package authserver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PathAuthorizationServerMetadata is the RFC 8414 discovery document.
+	PathAuthorizationServerMetadata = "/.well-known/oauth-authorization-server"
+	// PathProtectedResourceMetadata is the RFC 9728 discovery document.
+	PathProtectedResourceMetadata = "/.well-known/oauth-protected-resource"
+	// PathRegister is the dynamic client registration endpoint.
+	PathRegister = "/register"
+	// PathAuthorize is the authorization endpoint.
+	PathAuthorize = "/authorize"
+	// PathToken is the token endpoint.
+	PathToken = "/token"
+
+	defaultTokenTTL   = time.Hour
+	defaultCodeTTL    = time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+	defaultMaxClients = 1000
+	// sweepInterval spaces out the scans for expired codes and tokens.
+	sweepInterval = time.Minute
+)
+
+// Options tunes the authorization server.
+type Options struct {
+	// Issuer is the externally visible base URL. When empty it is derived
+	// from each request's scheme and host.
+	Issuer string
+	// TokenTTL bounds the lifetime of issued access tokens.
+	TokenTTL time.Duration
+	// MaxClients caps the registered clients; zero uses 1000.
+	MaxClients int
+	// Approve, when set, must accept a request to /register or /authorize
+	// before it is served, so only callers holding some other credential
+	// can obtain tokens. Nil approves every request: consent is implicit.
+	Approve func(r *http.Request) bool
+	// RedirectSchemes lists the lower-case private-use URI schemes that
+	// native clients may register as redirect targets. Only https and
+	// loopback http are accepted otherwise.
+	RedirectSchemes []string
+	// Now overrides the clock for tests.
+	Now func() time.Time
+}
+
+// Server is an in-memory OAuth 2.1 authorization server.
+type Server struct {
+	issuer     string
+	tokenTTL   time.Duration
+	maxClients int
+	approve    func(r *http.Request) bool
+	schemes    map[string]bool
+	now        func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	codes     map[string]*authCode
+	tokens    map[string]*grant
+	refresh   map[string]*grant
+	lastSweep time.Time
+}
+
+// client is a dynamically registered OAuth client.
+type client struct {
+	ID           string
+	SecretHash   string
+	Name         string
+	RedirectURIs []string
+	AuthMethod   string
+	IssuedAt     time.Time
+}
+
+// authCode is a pending authorization code bound to a PKCE challenge.
+type authCode struct {
+	ClientID      string
+	RedirectURI   string
+	Challenge     string
+	Scope         string
+	Resource      string
+	ExpiresAt     time.Time
+	RedirectGiven bool
+}
+
+// grant records the client and scope behind an access or refresh token.
+type grant struct {
+	ClientID  string
+	Scope     string
+	Resource  string
+	ExpiresAt time.Time
+}
+
+// New constructs an authorization server.
+func New(opts Options) *Server {
+	s := &Server{
+		issuer:     strings.TrimSuffix(opts.Issuer, "/"),
+		tokenTTL:   opts.TokenTTL,
+		maxClients: opts.MaxClients,
+		approve:    opts.Approve,
+		schemes:    make(map[string]bool),
+		now:        opts.Now,
+		clients:    make(map[string]*client),
+		codes:      make(map[string]*authCode),
+		tokens:     make(map[string]*grant),
+		refresh:    make(map[string]*grant),
+	}
+	for _, scheme := range opts.RedirectSchemes {
+		s.schemes[strings.ToLower(scheme)] = true
+	}
+	if s.tokenTTL <= 0 {
+		s.tokenTTL = defaultTokenTTL
+	}
+	if s.maxClients <= 0 {
+		s.maxClients = defaultMaxClients
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	return s
+}
+
+// Handles reports whether path belongs to the authorization server.
+func (s *Server) Handles(path string) bool {
+	switch path {
+	case PathRegister, PathAuthorize, PathToken:
+		return true
+	}
+	return strings.HasPrefix(path, PathAuthorizationServerMetadata) ||
+		strings.HasPrefix(path, PathProtectedResourceMetadata)
+}
+
+// ServeHTTP dispatches to the metadata, registration, authorization and token
+// endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.URL.Path == PathRegister || r.URL.Path == PathAuthorize) && s.approve != nil && !s.approve(r) {
+		oauthError(w, http.StatusUnauthorized, "access_denied", "a valid inbound credential is required to register or authorize clients")
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, PathAuthorizationServerMetadata):
+		s.serveAuthorizationServerMetadata(w, r)
+	case strings.HasPrefix(r.URL.Path, PathProtectedResourceMetadata):
+		s.serveProtectedResourceMetadata(w, r)
+	case r.URL.Path == PathRegister:
+		s.serveRegister(w, r)
+	case r.URL.Path == PathAuthorize:
+		s.serveAuthorize(w, r)
+	case r.URL.Path == PathToken:
+		s.serveToken(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ValidateToken reports whether token is a live access token and returns the
+// client it was issued to.
+func (s *Server) ValidateToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	key := hashToken(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	g, ok := s.tokens[key]
+	if !ok {
+		return "", false
+	}
+	if !s.now().Before(g.ExpiresAt) {
+		delete(s.tokens, key)
+		return "", false
+	}
+	return g.ClientID, true
+}
+
+// ResourceMetadataURL returns the protected-resource metadata URL advertised
+// in WWW-Authenticate challenges.
+func (s *Server) ResourceMetadataURL(r *http.Request) string {
+	return s.issuerFor(r) + PathProtectedResourceMetadata
+}
+
+// issuerFor returns the configured issuer or derives one from the request.
+func (s *Server) issuerFor(r *http.Request) string {
+	if s.issuer != "" {
+		return s.issuer
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// serveAuthorizationServerMetadata answers RFC 8414 discovery.
+func (s *Server) serveAuthorizationServerMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	issuer := s.issuerFor(r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + PathAuthorize,
+		"token_endpoint":                        issuer + PathToken,
+		"registration_endpoint":                 issuer + PathRegister,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
+	})
+}
+
+// serveProtectedResourceMetadata answers RFC 9728 discovery. A path suffix,
+// as in /.well-known/oauth-protected-resource/mcp, names the resource.
+func (s *Server) serveProtectedResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	issuer := s.issuerFor(r)
+	resource := issuer + strings.TrimPrefix(r.URL.Path, PathProtectedResourceMetadata)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"resource":                 resource,
+		"authorization_servers":    []string{issuer},
+		"bearer_methods_supported": []string{"header"},
+	})
+}
+
+// registrationRequest is the subset of RFC 7591 client metadata we honour.
+type registrationRequest struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	ClientName              string   `json:"client_name"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+}
+
+// serveRegister implements RFC 7591 dynamic client registration.
+func (s *Server) serveRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req registrationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_client_metadata", "request body must be JSON client metadata")
+		return
+	}
+	if len(req.RedirectURIs) == 0 {
+		oauthError(w, http.StatusBadRequest, "invalid_redirect_uri", "at least one redirect_uri is required")
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !s.validRedirectURI(uri) {
+			oauthError(w, http.StatusBadRequest, "invalid_redirect_uri", "redirect_uri must be absolute, without fragment, and use https, http on loopback, or an allowed private-use scheme")
+			return
+		}
+	}
+
+	method := req.TokenEndpointAuthMethod
+	switch method {
+	case "":
+		method = "none"
+	case "none", "client_secret_basic", "client_secret_post":
+	default:
+		oauthError(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported token_endpoint_auth_method")
+		return
+	}
+
+	c := &client{
+		ID:           randomToken(),
+		Name:         req.ClientName,
+		RedirectURIs: req.RedirectURIs,
+		AuthMethod:   method,
+		IssuedAt:     s.now(),
+	}
+	resp := map[string]any{
+		"client_id":                  c.ID,
+		"client_id_issued_at":        c.IssuedAt.Unix(),
+		"client_name":                c.Name,
+		"redirect_uris":              c.RedirectURIs,
+		"token_endpoint_auth_method": c.AuthMethod,
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+	}
+	if method != "none" {
+		secret := randomToken()
+		c.SecretHash = hashToken(secret)
+		resp["client_secret"] = secret
+		resp["client_secret_expires_at"] = 0
+	}
+
+	s.mu.Lock()
+	s.sweepLocked()
+	if len(s.clients) >= s.maxClients {
+		s.evictIdleClientsLocked()
+	}
+	full := len(s.clients) >= s.maxClients
+	if !full {
+		s.clients[c.ID] = c
+	}
+	s.mu.Unlock()
+	if full {
+		oauthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "too many registered clients")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// serveAuthorize issues an authorization code. There is no consent page: any
+// registered client that passed Options.Approve and presents a valid PKCE
+// challenge and redirect URI is approved immediately.
+func (s *Server) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+
+	s.mu.Lock()
+	c, ok := s.clients[q.Get("client_id")]
+	s.mu.Unlock()
+	if !ok {
+		// Never redirect to an unverified URI.
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI := q.Get("redirect_uri")
+	switch {
+	case redirectURI == "" && len(c.RedirectURIs) == 1:
+		redirectURI = c.RedirectURIs[0]
+	case !contains(c.RedirectURIs, redirectURI):
+		http.Error(w, "redirect_uri does not match a registered value", http.StatusBadRequest)
+		return
+	}
+
+	state := q.Get("state")
+	if q.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, state, "unsupported_response_type", "only response_type=code is supported")
+		return
+	}
+	challenge := q.Get("code_challenge")
+	if challenge == "" || q.Get("code_challenge_method") != "S256" {
+		redirectError(w, r, redirectURI, state, "invalid_request", "PKCE with code_challenge_method=S256 is required")
+		return
+	}
+
+	code := randomToken()
+	s.mu.Lock()
+	s.sweepLocked()
+	s.codes[hashToken(code)] = &authCode{
+		ClientID:      c.ID,
+		RedirectURI:   redirectURI,
+		Challenge:     challenge,
+		Scope:         q.Get("scope"),
+		Resource:      q.Get("resource"),
+		ExpiresAt:     s.now().Add(defaultCodeTTL),
+		RedirectGiven: q.Get("redirect_uri") != "",
+	}
+	s.mu.Unlock()
+
+	params := url.Values{"code": {code}, "iss": {s.issuerFor(r)}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+// serveToken implements the authorization_code and refresh_token grants.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	c, ok := s.authenticateClient(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mcp-auth-proxy"`)
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.exchangeCode(w, r, c)
+	case "refresh_token":
+		s.exchangeRefreshToken(w, r, c)
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code or refresh_token")
+	}
+}
+
+// exchangeCode redeems a single-use authorization code after verifying PKCE.
+func (s *Server) exchangeCode(w http.ResponseWriter, r *http.Request, c *client) {
+	form := r.PostForm
+	key := hashToken(form.Get("code"))
+
+	s.mu.Lock()
+	code, ok := s.codes[key]
+	// Codes are single use whether or not the exchange succeeds.
+	delete(s.codes, key)
+	s.mu.Unlock()
+
+	switch {
+	case !ok || !s.now().Before(code.ExpiresAt) || code.ClientID != c.ID:
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired")
+		return
+	case code.RedirectGiven && form.Get("redirect_uri") != code.RedirectURI:
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
+	case !verifyPKCE(form.Get("code_verifier"), code.Challenge):
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	s.issueTokens(w, c.ID, code.Scope, code.Resource)
+}
+
+// exchangeRefreshToken rotates a refresh token and issues a new access token.
+func (s *Server) exchangeRefreshToken(w http.ResponseWriter, r *http.Request, c *client) {
+	key := hashToken(r.PostForm.Get("refresh_token"))
+
+	s.mu.Lock()
+	g, ok := s.refresh[key]
+	delete(s.refresh, key)
+	s.mu.Unlock()
+
+	if !ok || !s.now().Before(g.ExpiresAt) || g.ClientID != c.ID {
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+		return
+	}
+
+	s.issueTokens(w, c.ID, g.Scope, g.Resource)
+}
+
+// issueTokens mints and records an access/refresh token pair.
+func (s *Server) issueTokens(w http.ResponseWriter, clientID, scope, resource string) {
+	access := randomToken()
+	refresh := randomToken()
+	now := s.now()
+
+	s.mu.Lock()
+	s.sweepLocked()
+	s.tokens[hashToken(access)] = &grant{ClientID: clientID, Scope: scope, Resource: resource, ExpiresAt: now.Add(s.tokenTTL)}
+	s.refresh[hashToken(refresh)] = &grant{ClientID: clientID, Scope: scope, Resource: resource, ExpiresAt: now.Add(defaultRefreshTTL)}
+	s.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	resp := map[string]any{
+		"access_token":  access,
+		"token_type":    "Bearer",
+		"expires_in":    int64(s.tokenTTL / time.Second),
+		"refresh_token": refresh,
+	}
+	if scope != "" {
+		resp["scope"] = scope
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authenticateClient resolves the client from basic auth or form parameters
+// and checks its secret when the client is confidential.
+func (s *Server) authenticateClient(r *http.Request) (*client, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes credentials in basic auth.
+		if decoded, err := url.QueryUnescape(id); err == nil {
+			id = decoded
+		}
+		if decoded, err := url.QueryUnescape(secret); err == nil {
+			secret = decoded
+		}
+	} else {
+		id = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	s.mu.Lock()
+	c, ok := s.clients[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	if c.AuthMethod == "none" {
+		return c, true
+	}
+	if secret == "" {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(c.SecretHash)) != 1 {
+		return nil, false
+	}
+	return c, true
+}
+
+// sweepLocked drops expired codes and tokens, at most once per
+// sweepInterval. The caller must hold s.mu.
+func (s *Server) sweepLocked() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, v := range s.codes {
+		if !now.Before(v.ExpiresAt) {
+			delete(s.codes, k)
+		}
+	}
+	for k, v := range s.tokens {
+		if !now.Before(v.ExpiresAt) {
+			delete(s.tokens, k)
+		}
+	}
+	for k, v := range s.refresh {
+		if !now.Before(v.ExpiresAt) {
+			delete(s.refresh, k)
+		}
+	}
+}
+
+// evictIdleClientsLocked drops clients without a pending code or live token
+// to make room for new registrations. Such a client can only continue by
+// authorizing again, which fails for an unknown client_id, so it has to
+// re-register as it would after a restart. The caller must hold s.mu.
+func (s *Server) evictIdleClientsLocked() {
+	active := make(map[string]bool)
+	for _, c := range s.codes {
+		active[c.ClientID] = true
+	}
+	for _, grants := range []map[string]*grant{s.tokens, s.refresh} {
+		for _, g := range grants {
+			active[g.ClientID] = true
+		}
+	}
+	for id := range s.clients {
+		if !active[id] {
+			delete(s.clients, id)
+		}
+	}
+}
+
+// verifyPKCE checks an S256 code_verifier against the stored challenge.
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// validRedirectURI accepts absolute URIs without fragments that use https,
+// http on a loopback host, or one of the configured private-use schemes.
+// Other schemes, such as javascript: or data:, are refused.
+func (s *Server) validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return u.Host != ""
+	case "http":
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return true
+		}
+		return false
+	}
+	return s.schemes[strings.ToLower(u.Scheme)]
+}
+
+// redirectError reports an authorization error to a verified redirect URI.
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state, code, description string) {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+// appendQuery merges params into the query string of raw.
+func appendQuery(raw string, params url.Values) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	for k, vv := range params {
+		q[k] = vv
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// oauthError writes an RFC 6749 section 5.2 error response.
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+// methodNotAllowed rejects unsupported verbs.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// writeJSON encodes v with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// randomToken returns 32 bytes of randomness encoded for URLs.
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand never fails on supported platforms.
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// hashToken stores credentials by digest so the maps never hold raw tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// contains reports whether list includes v.
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
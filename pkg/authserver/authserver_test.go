@@ -0,0 +1,339 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package authserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-extra-entropy"
+
+func TestMetadataDocuments(t *testing.T) {
+	srv := New(Options{Issuer: "https://proxy.example.com/"})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathAuthorizationServerMetadata, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	want := map[string]string{
+		"issuer":                 "https://proxy.example.com",
+		"authorization_endpoint": "https://proxy.example.com/authorize",
+		"token_endpoint":         "https://proxy.example.com/token",
+		"registration_endpoint":  "https://proxy.example.com/register",
+	}
+	for k, v := range want {
+		if meta[k] != v {
+			t.Errorf("%s mismatch: got %v want %s", k, meta[k], v)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathProtectedResourceMetadata+"/mcp", nil))
+	var resource map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resource); err != nil {
+		t.Fatalf("decode resource metadata: %v", err)
+	}
+	if resource["resource"] != "https://proxy.example.com/mcp" {
+		t.Fatalf("unexpected resource: %v", resource["resource"])
+	}
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	srv := New(Options{})
+
+	clientID := register(t, srv, `{"redirect_uris":["http://127.0.0.1:33418/callback"],"client_name":"agent"}`)
+
+	code := authorize(t, srv, clientID, "http://127.0.0.1:33418/callback", challengeFor(testVerifier))
+
+	// A wrong verifier burns the code.
+	rec := postForm(srv, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {"http://127.0.0.1:33418/callback"},
+		"code_verifier": {strings.Repeat("x", 43)},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Fatalf("expected invalid_grant for wrong verifier, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	code = authorize(t, srv, clientID, "http://127.0.0.1:33418/callback", challengeFor(testVerifier))
+	rec = postForm(srv, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {"http://127.0.0.1:33418/callback"},
+		"code_verifier": {testVerifier},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token exchange failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("decode token response: %v", err)
+	}
+	if tokens.TokenType != "Bearer" {
+		t.Fatalf("unexpected token type %q", tokens.TokenType)
+	}
+	if got, ok := srv.ValidateToken(tokens.AccessToken); !ok || got != clientID {
+		t.Fatalf("issued token did not validate: %q %v", got, ok)
+	}
+
+	// Codes are single use.
+	rec = postForm(srv, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"code_verifier": {testVerifier},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected replayed code to fail, got %d", rec.Code)
+	}
+
+	rec = postForm(srv, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {tokens.RefreshToken},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh failed: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTokensExpire(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	srv := New(Options{TokenTTL: time.Minute, Now: func() time.Time { return now }})
+
+	clientID := register(t, srv, `{"redirect_uris":["https://agent.example.com/cb"]}`)
+	code := authorize(t, srv, clientID, "https://agent.example.com/cb", challengeFor(testVerifier))
+	rec := postForm(srv, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"code_verifier": {testVerifier},
+	})
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("decode token response: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := srv.ValidateToken(tokens.AccessToken); ok {
+		t.Fatal("expected expired token to be rejected")
+	}
+}
+
+func TestRegistrationAcceptsRedirectURIs(t *testing.T) {
+	srv := New(Options{RedirectSchemes: []string{"com.example.agent"}})
+
+	for _, uri := range []string{
+		"https://agent.example.com/cb",
+		"http://127.0.0.1:33418/cb",
+		"http://[::1]:33418/cb",
+		"com.example.agent:/cb",
+	} {
+		t.Run(uri, func(t *testing.T) {
+			register(t, srv, `{"redirect_uris":["`+uri+`"]}`)
+		})
+	}
+}
+
+func TestRegistrationValidation(t *testing.T) {
+	srv := New(Options{RedirectSchemes: []string{"com.example.agent"}})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing redirect", body: `{}`},
+		{name: "remote http", body: `{"redirect_uris":["http://evil.example.com/cb"]}`},
+		{name: "relative", body: `{"redirect_uris":["/cb"]}`},
+		{name: "javascript scheme", body: `{"redirect_uris":["javascript:alert(1)"]}`},
+		{name: "unlisted scheme", body: `{"redirect_uris":["com.other.app:/cb"]}`},
+		{name: "https without host", body: `{"redirect_uris":["https:/cb"]}`},
+		{name: "auth method", body: `{"redirect_uris":["https://a.example.com/cb"],"token_endpoint_auth_method":"private_key_jwt"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathRegister, strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRejectsUnregisteredRedirect(t *testing.T) {
+	srv := New(Options{})
+	clientID := register(t, srv, `{"redirect_uris":["http://localhost:9000/cb"]}`)
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {"http://localhost:9000/other"},
+		"code_challenge":        {challengeFor(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathAuthorize+"?"+q.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unregistered redirect, got %d", rec.Code)
+	}
+}
+
+func TestApproveGatesRegistrationAndAuthorization(t *testing.T) {
+	approved := true
+	srv := New(Options{Approve: func(r *http.Request) bool { return approved }})
+	clientID := register(t, srv, `{"redirect_uris":["http://localhost:9000/cb"]}`)
+
+	approved = false
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathRegister, strings.NewReader(`{"redirect_uris":["http://localhost:9000/cb"]}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unapproved registration answered %d", rec.Code)
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"code_challenge":        {challengeFor(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathAuthorize+"?"+q.Encode(), nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Location") != "" {
+		t.Fatalf("unapproved authorization answered %d with location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathAuthorizationServerMetadata, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metadata must stay public, got %d", rec.Code)
+	}
+}
+
+func TestRegistrationIsCapped(t *testing.T) {
+	srv := New(Options{MaxClients: 2})
+	const body = `{"redirect_uris":["http://localhost:9000/cb"]}`
+	active := register(t, srv, body)
+	authorize(t, srv, active, "http://localhost:9000/cb", challengeFor(testVerifier))
+	register(t, srv, body)
+
+	// The idle client makes room; the one with a pending code stays.
+	register(t, srv, body)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathRegister, strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("registration after eviction answered %d", rec.Code)
+	}
+	if _, ok := srv.clients[active]; !ok {
+		t.Fatal("client with a pending code was evicted")
+	}
+
+	// A client with a pending code cannot be evicted.
+	full := New(Options{MaxClients: 1})
+	authorize(t, full, register(t, full, body), "http://localhost:9000/cb", challengeFor(testVerifier))
+	rec = httptest.NewRecorder()
+	full.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathRegister, strings.NewReader(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("registration past the cap answered %d", rec.Code)
+	}
+}
+
+func TestExpiredGrantsAreSweptWithoutAuthorize(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	srv := New(Options{TokenTTL: time.Minute, Now: func() time.Time { return now }})
+	clientID := register(t, srv, `{"redirect_uris":["https://agent.example.com/cb"]}`)
+	authorize(t, srv, clientID, "https://agent.example.com/cb", challengeFor(testVerifier))
+	srv.mu.Lock()
+	srv.tokens["stale"] = &grant{ClientID: clientID, ExpiresAt: now.Add(time.Minute)}
+	srv.mu.Unlock()
+
+	now = now.Add(2 * time.Minute)
+	srv.ValidateToken("unrelated")
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.codes) != 0 || len(srv.tokens) != 0 {
+		t.Fatalf("expired grants kept: %d codes, %d tokens", len(srv.codes), len(srv.tokens))
+	}
+}
+
+func register(t *testing.T, srv *Server, body string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathRegister, strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("registration failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ClientID string `json:"client_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode registration: %v", err)
+	}
+	return resp.ClientID
+}
+
+func authorize(t *testing.T, srv *Server, clientID, redirectURI, challenge string) string {
+	t.Helper()
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+		"state":                 {"xyz"},
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathAuthorize+"?"+q.Encode(), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorize failed: %d %s", rec.Code, rec.Body.String())
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if got := location.Query().Get("state"); got != "xyz" {
+		t.Fatalf("state not echoed: %q", got)
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("no code in redirect %s", location)
+	}
+	return code
+}
+
+func postForm(srv *Server, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, PathToken, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func challengeFor(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -4,10 +4,12 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +29,58 @@ const (
 	envServerWriteTimeout     = "MCP_SERVER_WRITE_TIMEOUT"
 	envServerIdleTimeout      = "MCP_SERVER_IDLE_TIMEOUT"
 	envGracefulShutdown       = "MCP_GRACEFUL_SHUTDOWN"
+	envTransport              = "MCP_TRANSPORT"
+	envStdioPath              = "MCP_STDIO_PATH"
+	envSignatureVersion       = "MCP_SIGNATURE_VERSION"
+	envSignedHeaders          = "MCP_SIGNED_HEADERS"
+	envAuthMode               = "MCP_AUTH_MODE"
+	envBearerToken            = "MCP_BEARER_TOKEN"
+	envOAuthTokenURL          = "MCP_OAUTH_TOKEN_URL"
+	envOAuthScopes            = "MCP_OAUTH_SCOPES"
+	envOAuthAudience          = "MCP_OAUTH_AUDIENCE"
+	envOAuthRefreshSkew       = "MCP_OAUTH_REFRESH_SKEW"
+	envAuthServerEnabled      = "MCP_AUTH_SERVER_ENABLED"
+	envAuthServerIssuer       = "MCP_AUTH_SERVER_ISSUER"
+	envAuthServerTokenTTL     = "MCP_AUTH_SERVER_TOKEN_TTL"
+	envAuthServerSchemes      = "MCP_AUTH_SERVER_REDIRECT_SCHEMES"
+	envInboundToken           = "MCP_INBOUND_TOKEN"
+	envInboundTokensFile      = "MCP_INBOUND_TOKENS_FILE"
+	envTLSCertFile            = "MCP_TLS_CERT_FILE"
+	envTLSKeyFile             = "MCP_TLS_KEY_FILE"
+	envTLSClientCAFile        = "MCP_TLS_CLIENT_CA_FILE"
+	envSSEMode                = "MCP_SSE_MODE"
+	envSigV4Region            = "MCP_SIGV4_REGION"
+	envSigV4Service           = "MCP_SIGV4_SERVICE"
+	envSigV4SessionToken      = "MCP_SIGV4_SESSION_TOKEN"
+	envStreamIdleTimeout      = "MCP_STREAM_IDLE_TIMEOUT"
+	envSessionTranslate       = "MCP_SESSION_TRANSLATE"
+	envSessionGracePeriod     = "MCP_SESSION_GRACE_PERIOD"
+	envSSEReplayBuffer        = "MCP_SSE_REPLAY_BUFFER"
+	envToolPolicyFile         = "MCP_TOOL_POLICY_FILE"
+	envAdminAddr              = "MCP_ADMIN_ADDR"
+	envReadyProbeInterval     = "MCP_READY_PROBE_INTERVAL"
+	envReadyProbePath         = "MCP_READY_PROBE_PATH"
+	envOTLPEndpoint           = "MCP_OTLP_ENDPOINT"
+	envServiceName            = "MCP_SERVICE_NAME"
+	envRetryMaxAttempts       = "MCP_RETRY_MAX_ATTEMPTS"
+	envRetryBaseDelay         = "MCP_RETRY_BASE_DELAY"
+	envRetryMaxDelay          = "MCP_RETRY_MAX_DELAY"
+	envRetryBudget            = "MCP_RETRY_BUDGET"
+	envRetryAllMethods        = "MCP_RETRY_ALL_METHODS"
+	envRetrySafeMethods       = "MCP_RETRY_SAFE_METHODS"
+	envBreakerFailures        = "MCP_BREAKER_CONSECUTIVE_FAILURES"
+	envBreakerErrorRate       = "MCP_BREAKER_ERROR_RATE"
+	envBreakerMinRequests     = "MCP_BREAKER_MIN_REQUESTS"
+	envBreakerOpenDuration    = "MCP_BREAKER_OPEN_DURATION"
+	envRateLimit              = "MCP_RATE_LIMIT"
+	envRateLimitKey           = "MCP_RATE_LIMIT_KEY"
+	envRateLimitMethods       = "MCP_RATE_LIMIT_METHODS"
+	envRateLimitTools         = "MCP_RATE_LIMIT_TOOLS"
+	envMaxConcurrentUpstream  = "MCP_MAX_CONCURRENT_UPSTREAM"
+	envUpstreamHeaders        = "MCP_UPSTREAM_HEADERS"
+	envToolPolicy             = "MCP_TOOL_POLICY"
+	envConfigWatchInterval    = "MCP_CONFIG_WATCH_INTERVAL"
+	envSecretRefreshInterval  = "MCP_SECRET_REFRESH_INTERVAL"
 	defaultListenAddr         = "127.0.0.1:8080"
 	defaultRequestTimeout     = 15 * time.Second
 	defaultSessionHeader      = "x-session-id"
@@ -35,8 +89,62 @@ const (
 	defaultServerWriteTimeout = 30 * time.Second
 	defaultServerIdleTimeout  = 120 * time.Second
 	defaultGracefulShutdown   = 10 * time.Second
+	defaultStdioPath          = "/mcp"
+	defaultSignatureVersion   = "v1"
+	defaultAuthMode           = "hmac"
+	defaultSigV4Service       = "execute-api"
+	defaultOAuthRefreshSkew   = 30 * time.Second
+	defaultAuthServerTokenTTL = time.Hour
+	defaultStreamIdleTimeout  = 5 * time.Minute
+	defaultSessionGracePeriod = 5 * time.Minute
+	defaultSSEReplayBuffer    = 100
+	defaultReadyProbeInterval = 30 * time.Second
+	defaultServiceName        = "mcp-auth-proxy"
+	defaultRetryMaxAttempts   = 1
+	defaultRetryBaseDelay     = 100 * time.Millisecond
+	defaultRetryMaxDelay      = 2 * time.Second
+	defaultRetryBudget        = 0.2
+	defaultBreakerFailures    = 0
+	defaultBreakerErrorRate   = 0
+	defaultBreakerMinRequests = 20
+	defaultBreakerOpenFor     = 30 * time.Second
 )
 
+const (
+	// RateLimitKeyIdentity keys rate limits by the authenticated inbound
+	// identity, falling back to the remote IP for anonymous clients.
+	RateLimitKeyIdentity = "identity"
+	// RateLimitKeyIP keys rate limits by the client's remote IP.
+	RateLimitKeyIP = "ip"
+	// RateLimitKeySession keys rate limits by the Mcp-Session-Id header,
+	// falling back to the remote IP before a session exists or when the id
+	// was not issued by the upstream.
+	RateLimitKeySession = "session"
+)
+
+const (
+	// SSEModeLocal answers GET /mcp with a local heartbeat stream.
+	SSEModeLocal = "local"
+	// SSEModeUpstream relays the upstream GET event stream and falls back to
+	// the local heartbeat when the upstream answers 404 or 405.
+	SSEModeUpstream = "upstream"
+)
+
+const (
+	// TransportHTTP serves MCP clients through the local HTTP listener.
+	TransportHTTP = "http"
+	// TransportStdio reads JSON-RPC messages from stdin and writes responses to
+	// stdout so the proxy can be launched as an MCP client subprocess.
+	TransportStdio = "stdio"
+)
+
+// RateLimit is a token bucket refilled at Rate tokens per second and holding
+// at most Burst tokens; a request spends one token per JSON-RPC message.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
 // Config captures runtime settings for the proxy.
 type Config struct {
 	ListenAddr              string
@@ -52,79 +160,560 @@ type Config struct {
 	ServerWriteTimeout      time.Duration
 	ServerIdleTimeout       time.Duration
 	GracefulShutdownTimeout time.Duration
+	Transport               string
+	StdioPath               string
+	SignatureVersion        string
+	SignedHeaders           []string
+	// AuthMode selects the upstream auth strategy: hmac, bearer, oauth2 or
+	// sigv4. APIKey/APISecret double as the OAuth2 client credentials and the
+	// SigV4 access key pair.
+	AuthMode          string
+	BearerToken       string
+	OAuthTokenURL     string
+	OAuthScopes       []string
+	OAuthAudience     string
+	OAuthRefreshSkew  time.Duration
+	SigV4Region       string
+	SigV4Service      string
+	SigV4SessionToken string
+	// AuthServerEnabled turns on the local OAuth 2.1 authorization server;
+	// inbound requests must then carry a token it issued.
+	AuthServerEnabled  bool
+	AuthServerIssuer   string
+	AuthServerTokenTTL time.Duration
+	// AuthServerRedirectSchemes lists the private-use URI schemes (such as
+	// com.example.app) that registered clients may redirect to, besides
+	// https and loopback http.
+	AuthServerRedirectSchemes []string
+	// InboundToken, InboundTokensFile and TLSClientCAFile authenticate local
+	// clients; a request passes when any configured method accepts it.
+	InboundToken      string
+	InboundTokensFile string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string
+	SSEMode           string
+	// StreamIdleTimeout replaces RequestTimeout once an upstream answers a
+	// POST with text/event-stream; zero disables the idle deadline.
+	StreamIdleTimeout time.Duration
+	// SessionTranslate hides upstream Mcp-Session-Id values behind ids issued
+	// by the proxy. SessionGracePeriod is how long a session may outlive its
+	// client's last connection before it is ended upstream; zero keeps it
+	// until shutdown.
+	SessionTranslate   bool
+	SessionGracePeriod time.Duration
+	// SSEReplayBuffer is the number of recent events kept per session for
+	// Last-Event-ID resumption; zero disables replay.
+	SSEReplayBuffer int
+	// ToolPolicyFile names a JSON policy restricting which tools clients may
+	// list and call; empty allows every tool.
+	ToolPolicyFile string
+	// ToolPolicy is an inline JSON policy document, typically written as a
+	// policy section of the config file; it excludes ToolPolicyFile.
+	ToolPolicy string
+	// UpstreamHeaders are static headers added to every upstream request
+	// before it is signed.
+	UpstreamHeaders map[string]string
+	// AdminAddr, when set, serves /metrics on a separate unauthenticated
+	// listener instead of the main one.
+	AdminAddr string
+	// ReadyProbeInterval is how often the upstream is probed for /readyz;
+	// zero leaves the upstream out of readiness. ReadyProbePath, when set,
+	// is probed with a signed GET instead of an MCP ping to /mcp.
+	ReadyProbeInterval time.Duration
+	ReadyProbePath     string
+	// OTLPEndpoint is the OTLP/HTTP collector URL spans are exported to;
+	// empty disables export. ServiceName is reported as service.name.
+	OTLPEndpoint string
+	ServiceName  string
+	// RetryMaxAttempts bounds upstream attempts per request, counting the
+	// first; the default of one disables retries. Delays grow from
+	// RetryBaseDelay up to RetryMaxDelay with full jitter. RetryBudget caps
+	// retries at that share of retryable requests; zero leaves them
+	// unbounded.
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryBudget      float64
+	// RetryAllMethods retries every request; otherwise only POSTs whose
+	// JSON-RPC methods are all in RetrySafeMethods (a built-in read-only set
+	// when empty) are retried.
+	RetryAllMethods  bool
+	RetrySafeMethods []string
+	// BreakerFailures opens the upstream circuit after that many failed
+	// round trips in a row, and BreakerErrorRate once that share of the last
+	// BreakerMinRequests round trips failed; zero disables either trigger.
+	// Both default to zero, so there is no breaker unless one is set. The
+	// circuit stays open for BreakerOpenDuration before a trial request is
+	// let through.
+	BreakerFailures     int
+	BreakerErrorRate    float64
+	BreakerMinRequests  int
+	BreakerOpenDuration time.Duration
+	// ClientRateLimit bounds the requests of each client, identified as
+	// RateLimitKey selects; MethodRateLimits and ToolRateLimits add per
+	// client limits for JSON-RPC methods and tools/call tool names (glob
+	// patterns allowed). A zero Rate disables a limit.
+	ClientRateLimit  RateLimit
+	RateLimitKey     string
+	MethodRateLimits map[string]RateLimit
+	ToolRateLimits   map[string]RateLimit
+	// MaxConcurrentUpstream caps upstream requests in flight across all
+	// clients; zero leaves them unbounded.
+	MaxConcurrentUpstream int
+	// Routes mount further upstreams under local path prefixes; requests
+	// outside every prefix go to Upstream.
+	Routes []Route
+	// AggregatePath, when set, serves one MCP endpoint that merges the
+	// tools, resources and prompts of Upstream and every route.
+	// AggregatePrefix is prepended to the tool and prompt names of this
+	// upstream there; each route has its own, "<name>_" by default.
+	AggregatePath   string
+	AggregatePrefix string
+	// ConfigFile is the YAML, JSON or TOML file the settings were read
+	// from, if any.
+	ConfigFile string
+	// ConfigWatchInterval is how often the config file and the tool policy
+	// file are checked for changes that trigger a reload; zero disables the
+	// watch, leaving SIGHUP as the only trigger.
+	ConfigWatchInterval time.Duration
+	// SecretRefreshInterval is how often secret references are resolved
+	// again, reloading the configuration when a value changed; zero keeps
+	// the values resolved at load time. SecretsDigest is a hash of the
+	// resolved values for that comparison, empty when no setting is a
+	// reference.
+	SecretRefreshInterval time.Duration
+	SecretsDigest         string
+	// Warnings describe settings that are valid but risky, for the caller
+	// to log.
+	Warnings []string
 }
 
-// Load reads configuration from environment variables and validates required values.
-func Load() (Config, error) {
-	upstreamRaw := strings.TrimSpace(os.Getenv(envUpstreamURL))
+// load reads every setting through l. Invalid and missing values are
+// recorded on l rather than returned, so all of them can be reported at once.
+func (l *loader) load() Config {
+	upstreamRaw, upstream := l.getURL(envUpstreamURL)
 	if upstreamRaw == "" {
-		return Config{}, errors.New("MCP_UPSTREAM_URL is required")
+		l.fail(errors.New("MCP_UPSTREAM_URL is required"))
 	}
 
-	upstream, err := url.Parse(upstreamRaw)
-	if err != nil {
-		return Config{}, fmt.Errorf("invalid MCP_UPSTREAM_URL: %w", err)
+	authMode := strings.ToLower(l.getString(envAuthMode, defaultAuthMode))
+	switch authMode {
+	case "hmac", "bearer", "oauth2", "sigv4":
+	default:
+		l.invalid(envAuthMode, authMode, "must be one of hmac, bearer, oauth2, sigv4")
+	}
+
+	apiKey := l.getSecret(envAPIKey)
+	apiSecret := l.getSecret(envAPISecret)
+	bearerToken := l.getSecret(envBearerToken)
+	if authMode == "bearer" {
+		if bearerToken == "" {
+			l.fail(errors.New("MCP_BEARER_TOKEN is required when MCP_AUTH_MODE=bearer"))
+		}
+	} else {
+		if apiKey == "" {
+			l.fail(errors.New("MCP_API_KEY is required"))
+		}
+		if apiSecret == "" {
+			l.fail(errors.New("MCP_API_SECRET is required"))
+		}
 	}
-	if !upstream.IsAbs() {
-		return Config{}, errors.New("MCP_UPSTREAM_URL must be absolute (scheme://host)")
+
+	tokenURL, _ := l.getURL(envOAuthTokenURL)
+	if authMode == "oauth2" && tokenURL == "" {
+		l.fail(errors.New("MCP_OAUTH_TOKEN_URL is required when MCP_AUTH_MODE=oauth2"))
+	}
+
+	sigV4Region := l.lookup(envSigV4Region)
+	if authMode == "sigv4" && sigV4Region == "" {
+		l.fail(errors.New("MCP_SIGV4_REGION is required when MCP_AUTH_MODE=sigv4"))
+	}
+
+	authServerIssuer, _ := l.getURL(envAuthServerIssuer)
+
+	tlsCertFile := l.lookup(envTLSCertFile)
+	tlsKeyFile := l.lookup(envTLSKeyFile)
+	tlsClientCAFile := l.lookup(envTLSClientCAFile)
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		l.fail(errors.New("MCP_TLS_CERT_FILE and MCP_TLS_KEY_FILE must be set together"))
+	}
+	if tlsClientCAFile != "" && tlsCertFile == "" {
+		l.fail(errors.New("MCP_TLS_CLIENT_CA_FILE requires MCP_TLS_CERT_FILE and MCP_TLS_KEY_FILE"))
+	}
+
+	sseMode := strings.ToLower(l.getString(envSSEMode, SSEModeLocal))
+	if sseMode != SSEModeLocal && sseMode != SSEModeUpstream {
+		l.invalid(envSSEMode, sseMode, fmt.Sprintf("must be %q or %q", SSEModeLocal, SSEModeUpstream))
+	}
+
+	transport := strings.ToLower(l.getString(envTransport, TransportHTTP))
+	if transport != TransportHTTP && transport != TransportStdio {
+		l.invalid(envTransport, transport, fmt.Sprintf("must be %q or %q", TransportHTTP, TransportStdio))
+	}
+
+	signatureVersion := strings.ToLower(l.getString(envSignatureVersion, defaultSignatureVersion))
+	if signatureVersion != "v1" && signatureVersion != "v2" {
+		l.invalid(envSignatureVersion, signatureVersion, "must be v1 or v2")
 	}
 
-	apiKey := strings.TrimSpace(os.Getenv(envAPIKey))
-	if apiKey == "" {
-		return Config{}, errors.New("MCP_API_KEY is required")
+	otlpEndpoint, _ := l.getURL(envOTLPEndpoint)
+
+	clientRateLimit := l.getRateLimit(envRateLimit)
+	rateLimitKey := strings.ToLower(l.getString(envRateLimitKey, RateLimitKeyIdentity))
+	switch rateLimitKey {
+	case RateLimitKeyIdentity, RateLimitKeyIP, RateLimitKeySession:
+	default:
+		l.invalid(envRateLimitKey, rateLimitKey, fmt.Sprintf("must be one of %s, %s, %s", RateLimitKeyIdentity, RateLimitKeyIP, RateLimitKeySession))
 	}
 
-	apiSecret := strings.TrimSpace(os.Getenv(envAPISecret))
-	if apiSecret == "" {
-		return Config{}, errors.New("MCP_API_SECRET is required")
+	toolPolicy := l.lookup(envToolPolicy)
+	if toolPolicy != "" && l.lookup(envToolPolicyFile) != "" {
+		l.fail(fmt.Errorf("%s and %s are mutually exclusive", envToolPolicy, envToolPolicyFile))
 	}
 
 	cfg := Config{
-		ListenAddr:              getString(envListenAddr, defaultListenAddr),
-		Upstream:                upstream,
-		APIKey:                  apiKey,
-		APISecret:               apiSecret,
-		SessionHeader:           getString(envSessionHeader, defaultSessionHeader),
-		SessionValue:            strings.TrimSpace(os.Getenv(envSessionValue)),
-		RequestTimeout:          getDuration(envRequestTimeout, defaultRequestTimeout),
-		InsecureSkipVerify:      getBool(envInsecureSkipVerify, false),
-		LogLevel:                strings.ToLower(getString(envLogLevel, defaultLogLevel)),
-		ServerReadTimeout:       getDuration(envServerReadTimeout, defaultServerReadTimeout),
-		ServerWriteTimeout:      getDuration(envServerWriteTimeout, defaultServerWriteTimeout),
-		ServerIdleTimeout:       getDuration(envServerIdleTimeout, defaultServerIdleTimeout),
-		GracefulShutdownTimeout: getDuration(envGracefulShutdown, defaultGracefulShutdown),
-	}
-
-	return cfg, nil
-}
-
-func getString(key, fallback string) string {
-	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+		ListenAddr:                l.getAddr(envListenAddr, defaultListenAddr),
+		Upstream:                  upstream,
+		APIKey:                    apiKey,
+		APISecret:                 apiSecret,
+		SessionHeader:             l.getString(envSessionHeader, defaultSessionHeader),
+		SessionValue:              l.getSecret(envSessionValue),
+		RequestTimeout:            l.getDuration(envRequestTimeout, defaultRequestTimeout),
+		InsecureSkipVerify:        l.getBool(envInsecureSkipVerify, false),
+		LogLevel:                  strings.ToLower(l.getString(envLogLevel, defaultLogLevel)),
+		ServerReadTimeout:         l.getDuration(envServerReadTimeout, defaultServerReadTimeout),
+		ServerWriteTimeout:        l.getDuration(envServerWriteTimeout, defaultServerWriteTimeout),
+		ServerIdleTimeout:         l.getDuration(envServerIdleTimeout, defaultServerIdleTimeout),
+		GracefulShutdownTimeout:   l.getDuration(envGracefulShutdown, defaultGracefulShutdown),
+		Transport:                 transport,
+		StdioPath:                 l.getString(envStdioPath, defaultStdioPath),
+		SignatureVersion:          signatureVersion,
+		SignedHeaders:             l.getList(envSignedHeaders),
+		AuthMode:                  authMode,
+		BearerToken:               bearerToken,
+		OAuthTokenURL:             tokenURL,
+		OAuthScopes:               l.getList(envOAuthScopes),
+		OAuthAudience:             l.lookup(envOAuthAudience),
+		OAuthRefreshSkew:          l.getOptionalDuration(envOAuthRefreshSkew, defaultOAuthRefreshSkew),
+		SigV4Region:               sigV4Region,
+		SigV4Service:              l.getString(envSigV4Service, defaultSigV4Service),
+		SigV4SessionToken:         l.getSecret(envSigV4SessionToken),
+		AuthServerEnabled:         l.getBool(envAuthServerEnabled, false),
+		AuthServerIssuer:          authServerIssuer,
+		AuthServerTokenTTL:        l.getDuration(envAuthServerTokenTTL, defaultAuthServerTokenTTL),
+		AuthServerRedirectSchemes: l.getSchemes(envAuthServerSchemes),
+		InboundToken:              l.getSecret(envInboundToken),
+		InboundTokensFile:         l.lookup(envInboundTokensFile),
+		TLSCertFile:               tlsCertFile,
+		TLSKeyFile:                tlsKeyFile,
+		TLSClientCAFile:           tlsClientCAFile,
+		SSEMode:                   sseMode,
+		StreamIdleTimeout:         l.getOptionalDuration(envStreamIdleTimeout, defaultStreamIdleTimeout),
+		SessionTranslate:          l.getBool(envSessionTranslate, false),
+		SessionGracePeriod:        l.getOptionalDuration(envSessionGracePeriod, defaultSessionGracePeriod),
+		SSEReplayBuffer:           l.getInt(envSSEReplayBuffer, defaultSSEReplayBuffer),
+		ToolPolicyFile:            l.lookup(envToolPolicyFile),
+		ToolPolicy:                toolPolicy,
+		UpstreamHeaders:           l.getMap(envUpstreamHeaders),
+		AdminAddr:                 l.getAddr(envAdminAddr, ""),
+		ReadyProbeInterval:        l.getOptionalDuration(envReadyProbeInterval, defaultReadyProbeInterval),
+		ReadyProbePath:            l.lookup(envReadyProbePath),
+		OTLPEndpoint:              otlpEndpoint,
+		ServiceName:               l.getString(envServiceName, defaultServiceName),
+		RetryMaxAttempts:          l.getInt(envRetryMaxAttempts, defaultRetryMaxAttempts),
+		RetryBaseDelay:            l.getDuration(envRetryBaseDelay, defaultRetryBaseDelay),
+		RetryMaxDelay:             l.getDuration(envRetryMaxDelay, defaultRetryMaxDelay),
+		RetryBudget:               l.getFloat(envRetryBudget, defaultRetryBudget),
+		RetryAllMethods:           l.getBool(envRetryAllMethods, false),
+		RetrySafeMethods:          l.getList(envRetrySafeMethods),
+		BreakerFailures:           l.getInt(envBreakerFailures, defaultBreakerFailures),
+		BreakerErrorRate:          l.getFloat(envBreakerErrorRate, defaultBreakerErrorRate),
+		BreakerMinRequests:        l.getInt(envBreakerMinRequests, defaultBreakerMinRequests),
+		BreakerOpenDuration:       l.getDuration(envBreakerOpenDuration, defaultBreakerOpenFor),
+		ClientRateLimit:           clientRateLimit,
+		RateLimitKey:              rateLimitKey,
+		MethodRateLimits:          l.getRateLimits(envRateLimitMethods),
+		ToolRateLimits:            l.getRateLimits(envRateLimitTools),
+		MaxConcurrentUpstream:     l.getInt(envMaxConcurrentUpstream, 0),
+		AggregatePath:             strings.TrimSuffix(l.lookup(envAggregatePath), "/"),
+		AggregatePrefix:           l.lookup(envAggregatePrefix),
+		ConfigWatchInterval:       l.getOptionalDuration(envConfigWatchInterval, 0),
+		SecretRefreshInterval:     l.getOptionalDuration(envSecretRefreshInterval, 0),
+	}
+
+	cfg.Routes = l.loadRoutes(cfg)
+	l.fail(checkAggregate(cfg))
+	l.fail(checkAuthServer(cfg))
+	cfg.Warnings = insecureWarnings(cfg)
+
+	return cfg
+}
+
+// fail records a configuration error; a nil err is ignored.
+func (l *loader) fail(err error) {
+	if err != nil {
+		l.errs = append(l.errs, err)
+	}
+}
+
+// invalid records a setting whose value cannot be used, naming the variable
+// and the offending input.
+func (l *loader) invalid(key, val, reason string) {
+	l.fail(fmt.Errorf("%s=%q: %s", key, val, reason))
+}
+
+func (l *loader) getString(key, fallback string) string {
+	if val := l.lookup(key); val != "" {
 		return val
 	}
 	return fallback
 }
 
-func getBool(key string, fallback bool) bool {
-	val := strings.TrimSpace(os.Getenv(key))
+func (l *loader) getList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(l.lookup(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (l *loader) getBool(key string, fallback bool) bool {
+	val := l.lookup(key)
 	if val == "" {
 		return fallback
 	}
 	parsed, err := strconv.ParseBool(val)
 	if err != nil {
+		l.invalid(key, val, "must be true or false")
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) getInt(key string, fallback int) int {
+	val := l.lookup(key)
+	if val == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(val)
+	if err != nil || parsed < 0 {
+		l.invalid(key, val, "must be a non-negative integer")
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) getFloat(key string, fallback float64) float64 {
+	val := l.lookup(key)
+	if val == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(val, 64)
+	if err != nil || parsed < 0 || math.IsInf(parsed, 0) || math.IsNaN(parsed) {
+		l.invalid(key, val, "must be a non-negative number")
 		return fallback
 	}
 	return parsed
 }
 
-func getDuration(key string, fallback time.Duration) time.Duration {
-	val := strings.TrimSpace(os.Getenv(key))
+// getDuration reads a positive duration with a unit, such as 15s.
+func (l *loader) getDuration(key string, fallback time.Duration) time.Duration {
+	return l.parseDuration(key, fallback, false)
+}
+
+// getOptionalDuration reads a duration for which 0 turns the feature off.
+func (l *loader) getOptionalDuration(key string, fallback time.Duration) time.Duration {
+	return l.parseDuration(key, fallback, true)
+}
+
+// parseDuration reads key as a duration, rejecting negative values and, unless
+// zeroOK, zero.
+func (l *loader) parseDuration(key string, fallback time.Duration, zeroOK bool) time.Duration {
+	val := l.lookup(key)
 	if val == "" {
 		return fallback
 	}
 	parsed, err := time.ParseDuration(val)
-	if err != nil {
+	switch {
+	case err != nil:
+		l.invalid(key, val, "must be a duration with a unit, such as 15s or 2m")
+		return fallback
+	case parsed < 0 || (parsed == 0 && !zeroOK):
+		l.invalid(key, val, "must be a positive duration")
 		return fallback
 	}
 	return parsed
 }
+
+// getURL reads an absolute http or https URL. It returns the raw value, empty
+// when unset, and the parsed URL, nil when unset or invalid.
+func (l *loader) getURL(key string) (string, *url.URL) {
+	val := l.lookup(key)
+	if val == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(val)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		l.invalid(key, val, "must be an absolute http or https URL")
+		return val, nil
+	}
+	return val, parsed
+}
+
+// getAddr reads a listen address in host:port form; the host may be empty to
+// listen on every interface.
+func (l *loader) getAddr(key, fallback string) string {
+	val := l.getString(key, fallback)
+	if val == "" {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(val)
+	if err == nil {
+		_, err = strconv.ParseUint(port, 10, 16)
+	}
+	if err != nil {
+		l.invalid(key, val, "must be host:port with a numeric port")
+	}
+	return val
+}
+
+// getSchemes reads a list of private-use URI schemes. Schemes are compared in
+// lower case; http and https are refused since their rules are fixed.
+func (l *loader) getSchemes(key string) []string {
+	schemes := l.getList(key)
+	for i, scheme := range schemes {
+		schemes[i] = strings.ToLower(scheme)
+		u, err := url.Parse(schemes[i] + ":")
+		if err != nil || u.Scheme != schemes[i] || schemes[i] == "http" || schemes[i] == "https" {
+			l.invalid(key, scheme, "must list URI schemes other than http and https")
+		}
+	}
+	return schemes
+}
+
+// getRateLimit reads a single rate[:burst] limit.
+func (l *loader) getRateLimit(key string) RateLimit {
+	val := l.lookup(key)
+	limit, err := parseRateLimit(val)
+	if err != nil {
+		l.invalid(key, val, err.Error())
+	}
+	return limit
+}
+
+// getRateLimits parses a name=rate[:burst] map such as getMap reads.
+func (l *loader) getRateLimits(key string) map[string]RateLimit {
+	var limits map[string]RateLimit
+	for name, spec := range l.getMap(key) {
+		limit, err := parseRateLimit(spec)
+		if err != nil {
+			l.invalid(key, name+"="+spec, err.Error())
+			continue
+		}
+		if limits == nil {
+			limits = make(map[string]RateLimit)
+		}
+		limits[name] = limit
+	}
+	return limits
+}
+
+// getMap parses a comma separated list of name=value entries, or the JSON
+// object a config file section is flattened to.
+func (l *loader) getMap(key string) map[string]string {
+	val := l.lookup(key)
+	if val == "" {
+		return nil
+	}
+	out := make(map[string]string)
+	if strings.HasPrefix(val, "{") {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(val), &obj); err != nil {
+			l.invalid(key, val, err.Error())
+			return nil
+		}
+		for name, value := range obj {
+			out[name] = fmt.Sprint(value)
+		}
+		return out
+	}
+	for _, item := range l.getList(key) {
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			l.invalid(key, item, "entries must be name=value")
+			continue
+		}
+		out[name] = strings.TrimSpace(value)
+	}
+	return out
+}
+
+// parseRateLimit parses "rate[:burst]", where rate is in requests per second.
+// The burst defaults to the rate rounded up, and at least one. An empty value
+// is the zero (disabled) limit.
+func parseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return RateLimit{}, nil
+	}
+	rateRaw, burstRaw, hasBurst := strings.Cut(value, ":")
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateRaw), 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return RateLimit{}, errors.New("rate must be a positive number of requests per second")
+	}
+	limit := RateLimit{Rate: rate, Burst: max(int(math.Ceil(rate)), 1)}
+	if hasBurst {
+		burst, err := strconv.Atoi(strings.TrimSpace(burstRaw))
+		if err != nil || burst < 1 {
+			return RateLimit{}, errors.New("burst must be a positive integer")
+		}
+		limit.Burst = burst
+	}
+	return limit, nil
+}
+
+// insecureWarnings flags upstreams whose TLS certificates go unverified
+// although they are not on this machine.
+func insecureWarnings(cfg Config) []string {
+	var warnings []string
+	check := func(name string, c Config) {
+		if c.InsecureSkipVerify && c.Upstream != nil && !isLocalHost(c.Upstream.Hostname()) {
+			warnings = append(warnings, fmt.Sprintf("%s skips TLS verification of the non-local host %s", name, c.Upstream.Hostname()))
+		}
+	}
+	check(envInsecureSkipVerify, cfg)
+	for _, route := range cfg.Routes {
+		check(routeKey(route.Name, envInsecureSkipVerify), route.Config)
+	}
+	return warnings
+}
+
+// checkAuthServer refuses an authorization server that would hand tokens to
+// anyone who can reach a non-loopback listener. Without an inbound token,
+// tokens file or client CA, registration and authorization are open.
+func checkAuthServer(cfg Config) error {
+	if !cfg.AuthServerEnabled || cfg.Transport != TransportHTTP ||
+		cfg.InboundToken != "" || cfg.InboundTokensFile != "" || cfg.TLSClientCAFile != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(cfg.ListenAddr)
+	if err != nil || isLocalHost(host) {
+		return nil
+	}
+	return fmt.Errorf("%s on the non-loopback %s=%q requires %s, %s or %s", envAuthServerEnabled,
+		envListenAddr, cfg.ListenAddr, envInboundToken, envInboundTokensFile, envTLSClientCAFile)
+}
+
+// isLocalHost reports whether host names this machine.
+func isLocalHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,250 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setBaseEnv sets the minimal valid configuration plus the given overrides.
+func setBaseEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Setenv(envUpstreamURL, "https://upstream.example.com")
+	t.Setenv(envAPIKey, "key")
+	t.Setenv(envAPISecret, "secret")
+	for key, val := range env {
+		t.Setenv(key, val)
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "duration without unit", env: map[string]string{envRequestTimeout: "15"}, want: `MCP_REQUEST_TIMEOUT="15": must be a duration with a unit`},
+		{name: "zero duration", env: map[string]string{envRequestTimeout: "0s"}, want: `MCP_REQUEST_TIMEOUT="0s": must be a positive duration`},
+		{name: "negative duration", env: map[string]string{envServerIdleTimeout: "-1s"}, want: `MCP_SERVER_IDLE_TIMEOUT="-1s": must be a positive duration`},
+		{name: "negative optional duration", env: map[string]string{envSessionGracePeriod: "-5m"}, want: `MCP_SESSION_GRACE_PERIOD="-5m"`},
+		{name: "bool", env: map[string]string{envInsecureSkipVerify: "yes"}, want: `MCP_UPSTREAM_INSECURE="yes": must be true or false`},
+		{name: "negative int", env: map[string]string{envSSEReplayBuffer: "-1"}, want: `MCP_SSE_REPLAY_BUFFER="-1": must be a non-negative integer`},
+		{name: "float", env: map[string]string{envRetryBudget: "lots"}, want: `MCP_RETRY_BUDGET="lots": must be a non-negative number`},
+		{name: "missing upstream", env: map[string]string{envUpstreamURL: ""}, want: "MCP_UPSTREAM_URL is required"},
+		{name: "upstream scheme", env: map[string]string{envUpstreamURL: "ftp://upstream.example.com"}, want: `MCP_UPSTREAM_URL="ftp://upstream.example.com": must be an absolute http or https URL`},
+		{name: "upstream without host", env: map[string]string{envUpstreamURL: "https:///mcp"}, want: `MCP_UPSTREAM_URL="https:///mcp"`},
+		{name: "otlp endpoint", env: map[string]string{envOTLPEndpoint: "collector:4318"}, want: `MCP_OTLP_ENDPOINT="collector:4318"`},
+		{name: "listen addr", env: map[string]string{envListenAddr: "8080"}, want: `MCP_LISTEN_ADDR="8080": must be host:port`},
+		{name: "admin port", env: map[string]string{envAdminAddr: "127.0.0.1:metrics"}, want: `MCP_ADMIN_ADDR="127.0.0.1:metrics"`},
+		{name: "rate limit", env: map[string]string{envRateLimit: "0"}, want: `MCP_RATE_LIMIT="0": rate must be a positive number`},
+		{name: "rate limit entry", env: map[string]string{envRateLimitTools: "fs_*=1:0"}, want: `MCP_RATE_LIMIT_TOOLS="fs_*=1:0": burst must be a positive integer`},
+		{name: "header entry", env: map[string]string{envUpstreamHeaders: "X-Tenant"}, want: `MCP_UPSTREAM_HEADERS="X-Tenant": entries must be name=value`},
+		{name: "enum", env: map[string]string{envSSEMode: "relay"}, want: `MCP_SSE_MODE="relay"`},
+		{name: "aggregate cap", env: map[string]string{envRoutes: "gh", "MCP_ROUTE_GH_UPSTREAM_URL": "https://gh.example.com", envAggregatePath: "/all", "MCP_ROUTE_GH_AGGREGATE_PREFIX": "gh_", envMaxConcurrentUpstream: "1"}, want: `MCP_MAX_CONCURRENT_UPSTREAM=1: must allow at least the 2 upstreams`},
+		{name: "open auth server", env: map[string]string{envAuthServerEnabled: "true", envListenAddr: "0.0.0.0:8080"}, want: `MCP_AUTH_SERVER_ENABLED on the non-loopback MCP_LISTEN_ADDR="0.0.0.0:8080"`},
+		{name: "redirect scheme", env: map[string]string{envAuthServerSchemes: "com.example.app,http"}, want: `MCP_AUTH_SERVER_REDIRECT_SCHEMES="http"`},
+		{name: "route url", env: map[string]string{envRoutes: "gh", "MCP_ROUTE_GH_UPSTREAM_URL": "gh.example.com", "MCP_ROUTE_GH_BEARER_TOKEN": "t", "MCP_ROUTE_GH_AUTH_MODE": "bearer"}, want: `MCP_ROUTE_GH_UPSTREAM_URL="gh.example.com"`},
+		{name: "route duration", env: map[string]string{envRoutes: "gh", "MCP_ROUTE_GH_UPSTREAM_URL": "https://gh.example.com", "MCP_ROUTE_GH_BEARER_TOKEN": "t", "MCP_ROUTE_GH_AUTH_MODE": "bearer", "MCP_ROUTE_GH_REQUEST_TIMEOUT": "30"}, want: `MCP_ROUTE_GH_REQUEST_TIMEOUT="30"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setBaseEnv(t, tc.env)
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadAllowsGuardedAuthServer(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "loopback listener", env: map[string]string{envListenAddr: "127.0.0.1:8080"}},
+		{name: "localhost listener", env: map[string]string{envListenAddr: "localhost:8080"}},
+		{name: "inbound token", env: map[string]string{envListenAddr: ":8080", envInboundToken: "agent-token"}},
+		{name: "tokens file", env: map[string]string{envListenAddr: ":8080", envInboundTokensFile: "/etc/mcp/tokens"}},
+		{name: "stdio transport", env: map[string]string{envListenAddr: ":8080", envTransport: TransportStdio}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.env[envAuthServerEnabled] = "true"
+			tc.env[envAuthServerSchemes] = "Com.Example.App"
+			setBaseEnv(t, tc.env)
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if len(cfg.AuthServerRedirectSchemes) != 1 || cfg.AuthServerRedirectSchemes[0] != "com.example.app" {
+				t.Fatalf("redirect schemes = %v", cfg.AuthServerRedirectSchemes)
+			}
+		})
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	setBaseEnv(t, map[string]string{
+		envAPISecret:          "",
+		envRequestTimeout:     "15",
+		envInsecureSkipVerify: "yes",
+		envListenAddr:         "localhost",
+	})
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("want one line per problem, got %q", err)
+	}
+	for _, want := range []string{envAPISecret, envRequestTimeout, envInsecureSkipVerify, envListenAddr} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %q", want, err)
+		}
+	}
+}
+
+func TestLoadAcceptsValidValues(t *testing.T) {
+	setBaseEnv(t, map[string]string{
+		envRequestTimeout:     "20s",
+		envInsecureSkipVerify: "true",
+		envStreamIdleTimeout:  "0",
+		envListenAddr:         ":9090",
+		envUpstreamURL:        "http://127.0.0.1:8000",
+	})
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.RequestTimeout != 20*time.Second || !cfg.InsecureSkipVerify || cfg.StreamIdleTimeout != 0 || cfg.ListenAddr != ":9090" {
+		t.Fatalf("unexpected settings %+v", cfg)
+	}
+	if len(cfg.Warnings) != 0 {
+		t.Fatalf("local insecure upstream should not warn: %v", cfg.Warnings)
+	}
+}
+
+func TestLoadKeepsResilienceFeaturesOptIn(t *testing.T) {
+	setBaseEnv(t, nil)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.RetryMaxAttempts != 1 {
+		t.Errorf("retries enabled by default: %d attempts", cfg.RetryMaxAttempts)
+	}
+	if cfg.BreakerFailures != 0 || cfg.BreakerErrorRate != 0 {
+		t.Errorf("circuit breaker enabled by default: %d failures, %v error rate", cfg.BreakerFailures, cfg.BreakerErrorRate)
+	}
+}
+
+func TestLoadRouteInheritance(t *testing.T) {
+	setBaseEnv(t, map[string]string{
+		envRoutes:                            "gh",
+		envAuthMode:                          "bearer",
+		envBearerToken:                       "top-token",
+		envRequestTimeout:                    "45s",
+		envSSEMode:                           "upstream",
+		envUpstreamHeaders:                   "X-Tenant=acme",
+		envInsecureSkipVerify:                "true",
+		routeKey("gh", envUpstreamURL):       "https://gh.example.com",
+		routeKey("gh", envBearerToken):       "gh-token",
+		routeKey("gh", envStreamIdleTimeout): "5s",
+		routeKey("gh", envUpstreamHeaders):   "X-Org=octo",
+	})
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	route := cfg.Routes[0].Config
+	if route.AuthMode != "bearer" || route.RequestTimeout != 45*time.Second || route.SSEMode != "upstream" {
+		t.Errorf("inherited settings not applied: %q %s %q", route.AuthMode, route.RequestTimeout, route.SSEMode)
+	}
+	if route.BearerToken != "gh-token" || route.StreamIdleTimeout != 5*time.Second {
+		t.Errorf("route settings not applied: %q %s", route.BearerToken, route.StreamIdleTimeout)
+	}
+	if route.InsecureSkipVerify || len(route.UpstreamHeaders) != 1 || route.UpstreamHeaders["X-Org"] != "octo" {
+		t.Errorf("TLS or headers inherited: insecure %v, headers %v", route.InsecureSkipVerify, route.UpstreamHeaders)
+	}
+}
+
+func TestLoadWarnsOnInsecureRemoteUpstream(t *testing.T) {
+	tests := []struct {
+		host string
+		warn bool
+	}{
+		{host: "localhost", warn: false},
+		{host: "api.localhost", warn: false},
+		{host: "127.0.0.1", warn: false},
+		{host: "[::1]", warn: false},
+		{host: "upstream.example.com", warn: true},
+		{host: "10.0.0.5", warn: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			setBaseEnv(t, map[string]string{
+				envUpstreamURL:        "https://" + tc.host + ":8443",
+				envInsecureSkipVerify: "true",
+			})
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := len(cfg.Warnings) > 0; got != tc.warn {
+				t.Fatalf("warnings %v, want warning %v", cfg.Warnings, tc.warn)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesSecretReferences(t *testing.T) {
+	secretFile := writeConfigFile(t, "api-secret", "first\n")
+	setBaseEnv(t, map[string]string{
+		envAPISecret:             "file://" + secretFile,
+		envBearerToken:           "exec:echo token",
+		envSecretRefreshInterval: "1m",
+	})
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.APISecret != "first" || cfg.BearerToken != "token" || cfg.SecretRefreshInterval != time.Minute {
+		t.Fatalf("secrets not resolved: secret %q token %q refresh %s", cfg.APISecret, cfg.BearerToken, cfg.SecretRefreshInterval)
+	}
+	if cfg.SecretsDigest == "" || strings.Contains(cfg.SecretsDigest, "first") {
+		t.Fatalf("unexpected digest %q", cfg.SecretsDigest)
+	}
+
+	if err := os.WriteFile(secretFile, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatalf("rotate secret: %v", err)
+	}
+	rotated, err := Load()
+	if err != nil {
+		t.Fatalf("load rotated: %v", err)
+	}
+	if rotated.APISecret != "rotated" || rotated.SecretsDigest == cfg.SecretsDigest {
+		t.Fatalf("rotation not picked up: secret %q digest unchanged %v", rotated.APISecret, rotated.SecretsDigest == cfg.SecretsDigest)
+	}
+
+	setBaseEnv(t, map[string]string{envBearerToken: ""})
+	plain, err := Load()
+	if err != nil {
+		t.Fatalf("load plain: %v", err)
+	}
+	if plain.SecretsDigest != "" {
+		t.Fatalf("digest %q set without secret references", plain.SecretsDigest)
+	}
+
+	setBaseEnv(t, map[string]string{envAPISecret: "file://" + filepath.Join(t.TempDir(), "missing")})
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), `MCP_API_SECRET="file://`) || strings.Count(err.Error(), "MCP_API_SECRET") != 1 {
+		t.Fatalf("unresolvable reference error = %v", err)
+	}
+}
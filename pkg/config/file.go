@@ -0,0 +1,283 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config/secrets"
+)
+
+// envConfigFile names the config file when no --config flag is given.
+const envConfigFile = "MCP_CONFIG_FILE"
+
+// fileRoutes is the config file key holding the route list.
+const fileRoutes = "routes"
+
+// Options selects the sources Load reads besides the environment.
+type Options struct {
+	// File is a YAML, JSON or TOML config file; empty falls back to
+	// MCP_CONFIG_FILE.
+	File string
+	// Flags holds settings given on the command line, keyed by variable
+	// name such as MCP_LISTEN_ADDR. They override the environment.
+	Flags map[string]string
+	// Secrets resolves secret references such as file:///run/secrets/x;
+	// nil uses secrets.Default.
+	Secrets *secrets.Resolver
+}
+
+// loader resolves settings from flags, then the environment, then the config
+// file; callers apply the defaults.
+type loader struct {
+	flags map[string]string
+	// file holds the config file flattened to variable names, and names the
+	// file key each came from for error messages.
+	file      map[string]string
+	fileNames map[string]string
+	// used records every variable read, so unknown file keys are caught.
+	used map[string]bool
+	// errs collects every invalid or missing setting.
+	errs []error
+	// secrets resolves secret references; digest hashes what they resolved
+	// to, and stays empty when no setting was a reference.
+	secrets *secrets.Resolver
+	digest  hash.Hash
+}
+
+// Load reads configuration from environment variables and the file named by
+// MCP_CONFIG_FILE, and validates them.
+func Load() (Config, error) {
+	return LoadWith(Options{})
+}
+
+// LoadWith reads configuration with the precedence flags > environment >
+// config file > defaults, and validates it. Every key of the file must name
+// a known setting. All problems found are returned together, one per line.
+func LoadWith(opts Options) (Config, error) {
+	l := &loader{flags: opts.Flags, used: make(map[string]bool), secrets: opts.Secrets}
+	if l.secrets == nil {
+		l.secrets = secrets.Default()
+	}
+
+	path := opts.File
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv(envConfigFile))
+	}
+	if path != "" {
+		var err error
+		if l.file, l.fileNames, err = readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	cfg := l.load()
+	cfg.ConfigFile = path
+	if l.digest != nil {
+		cfg.SecretsDigest = hex.EncodeToString(l.digest.Sum(nil))
+	}
+
+	var unknown []string
+	for key := range l.file {
+		if !l.used[key] {
+			unknown = append(unknown, l.fileNames[key])
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		l.fail(fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", ")))
+	}
+	if err := errors.Join(l.errs...); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// lookup returns the trimmed value of a setting from the first source that
+// sets it.
+func (l *loader) lookup(key string) string {
+	l.used[key] = true
+	if val, ok := l.flags[key]; ok {
+		return strings.TrimSpace(val)
+	}
+	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+		return val
+	}
+	return strings.TrimSpace(l.file[key])
+}
+
+// getSecret reads a credential, resolving it when it is a secret reference.
+func (l *loader) getSecret(key string) string {
+	val := l.lookup(key)
+	if !l.secrets.IsReference(val) {
+		return val
+	}
+	secret, err := l.secrets.Resolve(context.Background(), val)
+	if err != nil {
+		l.invalid(key, val, err.Error())
+		// Keep the reference so the required-value checks do not report
+		// the same setting again; the load fails either way.
+		return val
+	}
+	if l.digest == nil {
+		l.digest = sha256.New()
+	}
+	for _, part := range []string{key, secret} {
+		l.digest.Write([]byte(part))
+		l.digest.Write([]byte{0})
+	}
+	return secret
+}
+
+// readFile decodes a config file by its extension and flattens it to
+// variable names.
+func readFile(path string) (map[string]string, map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, nil, fmt.Errorf("config file %s: unsupported extension %q (want .yaml, .yml, .json or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	names := make(map[string]string)
+	if err := flattenFile(doc, values, names); err != nil {
+		return nil, nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, names, nil
+}
+
+// flattenFile maps file keys to variable names: upstream_url becomes
+// MCP_UPSTREAM_URL. Lists are joined with commas and sections are encoded as
+// JSON objects. Each entry of the routes list is a table with a name, whose
+// other keys become MCP_ROUTE_<NAME>_* variables.
+func flattenFile(doc map[string]any, values, names map[string]string) error {
+	for key, value := range doc {
+		if key == fileRoutes {
+			if err := flattenRoutes(value, values, names); err != nil {
+				return err
+			}
+			continue
+		}
+		flat, err := flattenValue(key, value)
+		if err != nil {
+			return err
+		}
+		variable := fileKey(key)
+		values[variable] = flat
+		names[variable] = key
+	}
+	return nil
+}
+
+// flattenRoutes expands the routes list into MCP_ROUTES and per-route keys.
+func flattenRoutes(value any, values, names map[string]string) error {
+	list, ok := value.([]any)
+	if tables, isTables := value.([]map[string]any); isTables {
+		// TOML decodes [[routes]] arrays of tables with their own type.
+		for _, table := range tables {
+			list = append(list, table)
+		}
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("%s must be a list", fileRoutes)
+	}
+	var routeNames []string
+	for i, item := range list {
+		if name, ok := item.(string); ok {
+			routeNames = append(routeNames, name)
+			continue
+		}
+		route, ok := asTable(item)
+		if !ok {
+			return fmt.Errorf("%s[%d] must be a name or a table", fileRoutes, i)
+		}
+		name, _ := route["name"].(string)
+		if name == "" {
+			return fmt.Errorf("%s[%d] needs a name", fileRoutes, i)
+		}
+		routeNames = append(routeNames, name)
+		for key, value := range route {
+			if key == "name" {
+				continue
+			}
+			flat, err := flattenValue(key, value)
+			if err != nil {
+				return fmt.Errorf("route %s: %w", name, err)
+			}
+			variable := routeKey(name, fileKey(key))
+			values[variable] = flat
+			names[variable] = fmt.Sprintf("%s[%s].%s", fileRoutes, name, key)
+		}
+	}
+	values[envRoutes] = strings.Join(routeNames, ",")
+	names[envRoutes] = fileRoutes
+	return nil
+}
+
+// flattenValue renders one file value as the string its variable would hold.
+func flattenValue(key string, value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := asTable(item); ok {
+				return "", fmt.Errorf("%s must be a list of values", key)
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// asTable reports whether value is a decoded mapping.
+func asTable(value any) (map[string]any, bool) {
+	table, ok := value.(map[string]any)
+	return table, ok
+}
+
+// fileKey returns the variable a file key stands for.
+func fileKey(key string) string {
+	return "MCP_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
@@ -0,0 +1,166 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a file named name in a temporary
+// directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"proxy.yaml": `
+upstream_url: https://upstream.example.com
+api_key: key
+api_secret: secret
+request_timeout: 20s
+oauth_scopes: [read, write]
+upstream_headers:
+  X-Tenant: acme, inc
+rate_limit_methods:
+  tools/call: "2:5"
+tool_policy:
+  deny: ["delete_*"]
+routes:
+  - name: github
+    upstream_url: https://github.example.com
+    auth_mode: bearer
+    bearer_token: gh-token
+`,
+		"proxy.json": `{
+  "upstream_url": "https://upstream.example.com",
+  "api_key": "key",
+  "api_secret": "secret",
+  "request_timeout": "20s",
+  "oauth_scopes": ["read", "write"],
+  "upstream_headers": {"X-Tenant": "acme, inc"},
+  "rate_limit_methods": {"tools/call": "2:5"},
+  "tool_policy": {"deny": ["delete_*"]},
+  "routes": [{"name": "github", "upstream_url": "https://github.example.com", "auth_mode": "bearer", "bearer_token": "gh-token"}]
+}`,
+		"proxy.toml": `
+upstream_url = "https://upstream.example.com"
+api_key = "key"
+api_secret = "secret"
+request_timeout = "20s"
+oauth_scopes = ["read", "write"]
+
+[upstream_headers]
+X-Tenant = "acme, inc"
+
+[rate_limit_methods]
+"tools/call" = "2:5"
+
+[tool_policy]
+deny = ["delete_*"]
+
+[[routes]]
+name = "github"
+upstream_url = "https://github.example.com"
+auth_mode = "bearer"
+bearer_token = "gh-token"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := LoadWith(Options{File: writeConfigFile(t, name, content)})
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Upstream.String() != "https://upstream.example.com" || cfg.APIKey != "key" || cfg.RequestTimeout != 20*time.Second {
+				t.Fatalf("unexpected settings %+v", cfg)
+			}
+			if strings.Join(cfg.OAuthScopes, " ") != "read write" || cfg.UpstreamHeaders["X-Tenant"] != "acme, inc" {
+				t.Fatalf("unexpected list or header settings %v %v", cfg.OAuthScopes, cfg.UpstreamHeaders)
+			}
+			if limit := cfg.MethodRateLimits["tools/call"]; limit.Rate != 2 || limit.Burst != 5 {
+				t.Fatalf("unexpected method limit %+v", limit)
+			}
+			if cfg.ToolPolicy != `{"deny":["delete_*"]}` {
+				t.Fatalf("unexpected inline policy %s", cfg.ToolPolicy)
+			}
+			if len(cfg.Routes) != 1 || cfg.Routes[0].Name != "github" || cfg.Routes[0].Config.BearerToken != "gh-token" ||
+				cfg.Routes[0].Config.RequestTimeout != 20*time.Second {
+				t.Fatalf("unexpected routes %+v", cfg.Routes)
+			}
+		})
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "proxy.yaml", `
+upstream_url: https://file.example.com
+api_key: file-key
+api_secret: file-secret
+listen_addr: 127.0.0.1:7000
+log_level: warn
+`)
+	t.Setenv(envConfigFile, path)
+	t.Setenv(envAPIKey, "env-key")
+	t.Setenv(envListenAddr, "127.0.0.1:8000")
+
+	cfg, err := LoadWith(Options{Flags: map[string]string{envListenAddr: "127.0.0.1:9000"}})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := []struct {
+		source string
+		got    string
+		want   string
+	}{
+		{source: "flag over env and file", got: cfg.ListenAddr, want: "127.0.0.1:9000"},
+		{source: "env over file", got: cfg.APIKey, want: "env-key"},
+		{source: "file over default", got: cfg.LogLevel, want: "warn"},
+		{source: "default", got: cfg.SessionHeader, want: defaultSessionHeader},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.source, tc.got, tc.want)
+		}
+	}
+	if cfg.ConfigFile != path {
+		t.Errorf("config file %q not recorded", path)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	base := "upstream_url: https://upstream.example.com\napi_key: key\napi_secret: secret\n"
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{name: "unknown key", file: "proxy.yaml", content: base + "upstream_ulr: x\n", want: "unknown keys upstream_ulr"},
+		{name: "unknown route key", file: "proxy.yaml", content: base + "routes:\n  - name: gh\n    upstream_url: https://gh.example.com\n    bearer_token: t\n    auth_mode: bearer\n    prefx: /x\n", want: "routes[gh].prefx"},
+		{name: "route without name", file: "proxy.yaml", content: base + "routes:\n  - upstream_url: https://gh.example.com\n", want: "needs a name"},
+		{name: "unsupported extension", file: "proxy.ini", content: base, want: "unsupported extension"},
+		{name: "malformed", file: "proxy.json", content: "{", want: "parse config file"},
+		{name: "policy twice", file: "proxy.yaml", content: base + "tool_policy_file: /p.json\ntool_policy: {deny: [x]}\n", want: "mutually exclusive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadWith(Options{File: writeConfigFile(t, tc.file, tc.content)})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// envRoutes lists the names of the routes; each route reads the
+	// MCP_ROUTE_<NAME>_* variant of the upstream keys.
+	envRoutes = "MCP_ROUTES"
+	// envRoutePrefix and envRouteRewrite only exist per route, as
+	// MCP_ROUTE_<NAME>_PREFIX and MCP_ROUTE_<NAME>_REWRITE.
+	envRoutePrefix  = "PREFIX"
+	envRouteRewrite = "REWRITE"
+	// envAggregatePath enables the aggregated endpoint; envAggregatePrefix
+	// also exists per route as MCP_ROUTE_<NAME>_AGGREGATE_PREFIX.
+	envAggregatePath   = "MCP_AGGREGATE_PATH"
+	envAggregatePrefix = "MCP_AGGREGATE_PREFIX"
+)
+
+// routeNamePattern restricts route names to what fits in a variable name.
+var routeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Route mounts an additional upstream under a local path prefix.
+type Route struct {
+	// Name identifies the route in logs and in its MCP_ROUTE_<NAME>_* keys.
+	Name string
+	// Prefix is the local path prefix, "/<name>" by default. Rewrite
+	// replaces it in the upstream path; empty strips it, so /github/mcp
+	// reaches the upstream as /mcp.
+	Prefix  string
+	Rewrite string
+	// Config holds the route's upstream settings: URL, credentials, session
+	// header, timeouts, TLS and SSE mode. The auth mode, signature version
+	// and signed headers, SigV4 region and service, session header, request
+	// and stream idle timeouts and SSE mode are inherited from the top level
+	// when the route does not set them. Credentials (API key and secret,
+	// bearer token, OAuth2 token URL, scopes and audience, SigV4 session
+	// token, session value), static upstream headers and
+	// MCP_UPSTREAM_INSECURE are not: a route without them has none, and
+	// verifies TLS.
+	Config Config
+}
+
+// routeKey returns the MCP_ROUTE_<NAME>_ variant of an MCP_ variable.
+func routeKey(name, key string) string {
+	return "MCP_ROUTE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + strings.TrimPrefix(key, "MCP_")
+}
+
+// loadRoutes reads the routes named in MCP_ROUTES, recording problems on l.
+func (l *loader) loadRoutes(base Config) []Route {
+	var routes []Route
+	prefixes := make(map[string]string)
+	for _, name := range l.getList(envRoutes) {
+		if !routeNamePattern.MatchString(name) {
+			l.invalid(envRoutes, name, "route names may only contain letters, digits, '-' and '_'")
+			continue
+		}
+		route := l.loadRoute(name, base)
+		if other, ok := prefixes[route.Prefix]; ok {
+			l.fail(fmt.Errorf("routes %q and %q share the prefix %s", other, name, route.Prefix))
+		}
+		prefixes[route.Prefix] = name
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// loadRoute reads one route, starting from the top-level settings.
+func (l *loader) loadRoute(name string, base Config) Route {
+	key := func(k string) string { return routeKey(name, k) }
+
+	route := Route{
+		Name:    name,
+		Prefix:  strings.TrimSuffix(l.getString(key(envRoutePrefix), "/"+name), "/"),
+		Rewrite: strings.TrimSuffix(l.lookup(key(envRouteRewrite)), "/"),
+	}
+	if !strings.HasPrefix(route.Prefix, "/") {
+		l.invalid(key(envRoutePrefix), route.Prefix, "must be an absolute path other than /")
+	}
+	if route.Rewrite != "" && !strings.HasPrefix(route.Rewrite, "/") {
+		l.invalid(key(envRouteRewrite), route.Rewrite, "must be an absolute path")
+	}
+
+	rawURL, upstream := l.getURL(key(envUpstreamURL))
+	if rawURL == "" {
+		l.fail(fmt.Errorf("%s is required", key(envUpstreamURL)))
+	}
+
+	cfg := base
+	cfg.Routes = nil
+	cfg.Upstream = upstream
+	cfg.AuthMode = strings.ToLower(l.getString(key(envAuthMode), base.AuthMode))
+	cfg.SignatureVersion = strings.ToLower(l.getString(key(envSignatureVersion), base.SignatureVersion))
+	if signed := l.getList(key(envSignedHeaders)); signed != nil {
+		cfg.SignedHeaders = signed
+	}
+	// Credentials and static headers are never inherited, so one
+	// upstream's secrets are not sent to another, and neither is disabled
+	// TLS verification.
+	cfg.APIKey = l.getSecret(key(envAPIKey))
+	cfg.APISecret = l.getSecret(key(envAPISecret))
+	cfg.BearerToken = l.getSecret(key(envBearerToken))
+	cfg.OAuthTokenURL, _ = l.getURL(key(envOAuthTokenURL))
+	cfg.OAuthScopes = l.getList(key(envOAuthScopes))
+	cfg.OAuthAudience = l.lookup(key(envOAuthAudience))
+	cfg.SigV4Region = l.getString(key(envSigV4Region), base.SigV4Region)
+	cfg.SigV4Service = l.getString(key(envSigV4Service), base.SigV4Service)
+	cfg.SigV4SessionToken = l.getSecret(key(envSigV4SessionToken))
+	cfg.SessionHeader = l.getString(key(envSessionHeader), base.SessionHeader)
+	cfg.SessionValue = l.getSecret(key(envSessionValue))
+	cfg.RequestTimeout = l.getDuration(key(envRequestTimeout), base.RequestTimeout)
+	cfg.StreamIdleTimeout = l.getOptionalDuration(key(envStreamIdleTimeout), base.StreamIdleTimeout)
+	cfg.InsecureSkipVerify = l.getBool(key(envInsecureSkipVerify), false)
+	cfg.SSEMode = strings.ToLower(l.getString(key(envSSEMode), base.SSEMode))
+	cfg.AggregatePrefix = l.getString(key(envAggregatePrefix), name+"_")
+	cfg.UpstreamHeaders = l.getMap(key(envUpstreamHeaders))
+
+	l.fail(checkRouteConfig(cfg, key))
+	route.Config = cfg
+	return route
+}
+
+// checkRouteConfig validates the upstream settings of a route and returns
+// every problem found.
+func checkRouteConfig(cfg Config, key func(string) string) error {
+	var errs []error
+	switch cfg.AuthMode {
+	case "bearer":
+		if cfg.BearerToken == "" {
+			errs = append(errs, fmt.Errorf("%s is required when the route uses bearer auth", key(envBearerToken)))
+		}
+	case "hmac", "oauth2", "sigv4":
+		if cfg.APIKey == "" || cfg.APISecret == "" {
+			errs = append(errs, fmt.Errorf("%s and %s are required", key(envAPIKey), key(envAPISecret)))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s=%q: must be one of hmac, bearer, oauth2, sigv4", key(envAuthMode), cfg.AuthMode))
+	}
+	if cfg.AuthMode == "oauth2" && cfg.OAuthTokenURL == "" {
+		errs = append(errs, fmt.Errorf("%s is required when the route uses oauth2", key(envOAuthTokenURL)))
+	}
+	if cfg.AuthMode == "sigv4" && cfg.SigV4Region == "" {
+		errs = append(errs, fmt.Errorf("%s is required when the route uses sigv4", key(envSigV4Region)))
+	}
+	if cfg.SignatureVersion != "v1" && cfg.SignatureVersion != "v2" {
+		errs = append(errs, fmt.Errorf("%s=%q: must be v1 or v2", key(envSignatureVersion), cfg.SignatureVersion))
+	}
+	if cfg.SSEMode != SSEModeLocal && cfg.SSEMode != SSEModeUpstream {
+		errs = append(errs, fmt.Errorf("%s=%q: must be %q or %q", key(envSSEMode), cfg.SSEMode, SSEModeLocal, SSEModeUpstream))
+	}
+	return errors.Join(errs...)
+}
+
+// checkAggregate validates the aggregated endpoint: its path, that the
+// concurrency cap leaves room to reach every upstream, and that no two
+// upstreams share a name prefix, which would make tool names ambiguous.
+func checkAggregate(cfg Config) error {
+	if cfg.AggregatePath == "" {
+		return nil
+	}
+	if !strings.HasPrefix(cfg.AggregatePath, "/") {
+		return fmt.Errorf("%s=%q: must be an absolute path other than /", envAggregatePath, cfg.AggregatePath)
+	}
+	if members := len(cfg.Routes) + 1; cfg.MaxConcurrentUpstream > 0 && cfg.MaxConcurrentUpstream < members {
+		// Aggregated requests are sent to every member at once.
+		return fmt.Errorf("%s=%d: must allow at least the %d upstreams behind %s", envMaxConcurrentUpstream, cfg.MaxConcurrentUpstream, members, envAggregatePath)
+	}
+	owners := map[string]string{cfg.AggregatePrefix: "the default upstream"}
+	for _, route := range cfg.Routes {
+		if other, ok := owners[route.Config.AggregatePrefix]; ok {
+			return fmt.Errorf("%s and route %q share the aggregate prefix %q", other, route.Name, route.Config.AggregatePrefix)
+		}
+		owners[route.Config.AggregatePrefix] = fmt.Sprintf("route %q", route.Name)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package secrets resolves references to secret values so credentials do not
+// have to sit in the environment or a config file in plain text. A setting
+// holds a reference instead of the secret:
+//
+//	MCP_API_SECRET=file:///run/secrets/mcp-api-secret
+//	MCP_API_SECRET=exec:pass show mcp/api-secret
+//	MCP_API_SECRET=keyring:mcp-auth-proxy/api-secret
+//
+// file:// reads a file, exec: runs a helper command and takes its standard
+// output, in the manner of git credential helpers, and keyring: looks the
+// secret up in the OS keyring through the D-Bus Secret Service. A trailing
+// newline is dropped from every value; a value without a known scheme is not
+// a reference and is used as is.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reference schemes registered by Default.
+const (
+	// SchemeFile reads the secret from an absolute file path.
+	SchemeFile = "file://"
+	// SchemeExec runs a command and reads the secret from its output.
+	SchemeExec = "exec:"
+	// SchemeKeyring reads the secret stored for <service>/<user> in the
+	// Secret Service keyring.
+	SchemeKeyring = "keyring:"
+)
+
+// defaultTimeout bounds helper commands so a stuck helper cannot stall
+// startup or a reload.
+const defaultTimeout = 10 * time.Second
+
+// maxStderr bounds how much of a failing helper's stderr is quoted in errors.
+const maxStderr = 200
+
+// Provider resolves the references of one scheme.
+type Provider interface {
+	// Resolve returns the secret ref names; ref excludes the scheme.
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver dispatches references to the provider registered for their
+// scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a Resolver without providers.
+func NewResolver() *Resolver {
+	return &Resolver{providers: make(map[string]Provider)}
+}
+
+// Default returns a Resolver with the file, exec and keyring providers.
+func Default() *Resolver {
+	r := NewResolver()
+	r.Register(SchemeFile, FileProvider{})
+	r.Register(SchemeExec, ExecProvider{})
+	r.Register(SchemeKeyring, KeyringProvider{})
+	return r
+}
+
+// Register makes p resolve references starting with scheme.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// lookup returns the provider for value and the reference after its scheme.
+func (r *Resolver) lookup(value string) (Provider, string, bool) {
+	for scheme, p := range r.providers {
+		if ref, ok := strings.CutPrefix(value, scheme); ok {
+			return p, ref, true
+		}
+	}
+	return nil, "", false
+}
+
+// IsReference reports whether value names a secret rather than holding one.
+func (r *Resolver) IsReference(value string) bool {
+	_, _, ok := r.lookup(value)
+	return ok
+}
+
+// Resolve returns the secret value references, or value itself when it is
+// not a reference. An empty secret is an error.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	p, ref, ok := r.lookup(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", errors.New("secret is empty")
+	}
+	return secret, nil
+}
+
+// FileProvider reads secrets from files such as Docker or Kubernetes secret
+// mounts.
+type FileProvider struct{}
+
+// Resolve reads the file at the absolute path ref.
+func (FileProvider) Resolve(_ context.Context, ref string) (string, error) {
+	if !filepath.IsAbs(ref) {
+		return "", fmt.Errorf("file reference %q must be an absolute path (file:///path)", ref)
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	return trimNewline(string(data)), nil
+}
+
+// ExecProvider runs a helper command and uses what it prints as the secret.
+// The command line is split on spaces and run without a shell.
+type ExecProvider struct {
+	// Timeout bounds the command; zero uses ten seconds.
+	Timeout time.Duration
+}
+
+// Resolve runs the command line ref.
+func (e ExecProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", errors.New("exec reference names no command")
+	}
+	return run(ctx, e.Timeout, args[0], args[1:]...)
+}
+
+// KeyringProvider reads secrets from the Secret Service keyring (GNOME
+// Keyring, KWallet and others) over the local D-Bus session, using
+// libsecret's secret-tool. Items are matched on the service and username
+// attributes, the convention of common keyring libraries, so a secret stored
+// with
+//
+//	secret-tool store --label=mcp service mcp-auth-proxy username api-secret
+//
+// is referenced as keyring:mcp-auth-proxy/api-secret.
+type KeyringProvider struct {
+	// Command is the secret-tool binary; empty looks it up on PATH.
+	Command string
+	// Timeout bounds the lookup; zero uses ten seconds.
+	Timeout time.Duration
+}
+
+// Resolve looks up ref, written as <service>/<user>.
+func (k KeyringProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	service, user, ok := strings.Cut(ref, "/")
+	if !ok || service == "" || user == "" {
+		return "", fmt.Errorf("keyring reference %q must be <service>/<user>", ref)
+	}
+	command := k.Command
+	if command == "" {
+		command = "secret-tool"
+	}
+	return run(ctx, k.Timeout, command, "lookup", "service", service, "username", user)
+}
+
+// run executes name with args and returns its standard output without the
+// trailing newline. Failures quote the start of standard error, never
+// standard output, which may hold part of a secret.
+func run(ctx context.Context, timeout time.Duration, name string, args ...string) (string, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if len(detail) > maxStderr {
+			detail = detail[:maxStderr] + "..."
+		}
+		if detail != "" {
+			return "", fmt.Errorf("run %s: %w: %s", name, err, detail)
+		}
+		return "", fmt.Errorf("run %s: %w", name, err)
+	}
+	return trimNewline(stdout.String()), nil
+}
+
+// trimNewline drops the line ending files and commands usually end with.
+func trimNewline(value string) string {
+	return strings.TrimRight(value, "\r\n")
+}
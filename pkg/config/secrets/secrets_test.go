@@ -0,0 +1,85 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "api-secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatalf("write empty file: %v", err)
+	}
+	// fake secret-tool that only answers the expected lookup.
+	secretTool := filepath.Join(dir, "secret-tool")
+	script := "#!/bin/sh\n" +
+		`if [ "$*" = "lookup service mcp-auth-proxy username api-secret" ]; then echo from-keyring; exit 0; fi` + "\n" +
+		"echo 'No matching item' >&2\nexit 1\n"
+	if err := os.WriteFile(secretTool, []byte(script), 0o700); err != nil {
+		t.Fatalf("write fake secret-tool: %v", err)
+	}
+
+	r := Default()
+	r.Register(SchemeKeyring, KeyringProvider{Command: secretTool})
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain value", value: "literal-secret", want: "literal-secret"},
+		{name: "file", value: "file://" + secretFile, want: "from-file"},
+		{name: "exec", value: "exec:echo from-helper", want: "from-helper"},
+		{name: "keyring", value: "keyring:mcp-auth-proxy/api-secret", want: "from-keyring"},
+		{name: "relative file", value: "file://run/secrets/x", wantErr: "absolute path"},
+		{name: "missing file", value: "file://" + filepath.Join(dir, "missing"), wantErr: "read secret file"},
+		{name: "empty file", value: "file://" + emptyFile, wantErr: "secret is empty"},
+		{name: "failing helper", value: "exec:false", wantErr: "run false"},
+		{name: "empty helper", value: "exec:  ", wantErr: "names no command"},
+		{name: "unknown keyring item", value: "keyring:mcp-auth-proxy/other", wantErr: "No matching item"},
+		{name: "malformed keyring reference", value: "keyring:mcp-auth-proxy", wantErr: "<service>/<user>"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := r.Resolve(context.Background(), tc.value)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want one containing %q", tc.value, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tc.value, err)
+			}
+			if got != tc.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunDoesNotQuoteStdout(t *testing.T) {
+	helper := filepath.Join(t.TempDir(), "helper")
+	if err := os.WriteFile(helper, []byte("#!/bin/sh\necho partial-secret\necho 'vault sealed' >&2\nexit 1\n"), 0o700); err != nil {
+		t.Fatalf("write helper: %v", err)
+	}
+	_, err := ExecProvider{}.Resolve(context.Background(), helper)
+	if err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Fatalf("error = %v, want the helper's stderr", err)
+	}
+	if strings.Contains(err.Error(), "partial-secret") {
+		t.Fatalf("error quotes helper output: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// fileStamp is what Watch compares to notice that a file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// statFile returns the current stamp of path, following symlinks so files
+// replaced by swapping a link (as Kubernetes does for mounted ConfigMaps and
+// Secrets) are noticed too.
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// Watch polls paths every interval until ctx ends and sends on changed when
+// any of them was modified, created or removed. Empty paths are skipped. A
+// send that would block is dropped, since one pending notice covers every
+// change before it is handled.
+func Watch(ctx context.Context, interval time.Duration, changed chan<- struct{}, paths ...string) {
+	stamps := make(map[string]fileStamp)
+	for _, path := range paths {
+		if path != "" {
+			stamps[path] = statFile(path)
+		}
+	}
+	if len(stamps) == 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modified := false
+		for path, old := range stamps {
+			if stamp := statFile(path); stamp != old {
+				stamps[path] = stamp
+				modified = true
+			}
+		}
+		if modified {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchNotifiesOnChange(t *testing.T) {
+	path := writeConfigFile(t, "proxy.yaml", "upstream_url: https://a.example.com\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, 10*time.Millisecond, changed, path, "", filepath.Join(t.TempDir(), "missing.json"))
+
+	select {
+	case <-changed:
+		t.Fatal("notified before anything changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("upstream_url: https://b.example.com/longer\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config file: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not noticed")
+	}
+}
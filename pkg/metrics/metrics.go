@@ -0,0 +1,249 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package metrics exposes Prometheus instrumentation for the proxy. Metric
+// names and labels are part of the public contract: dashboards depend on
+// them, so they must not be renamed.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Upstream error classes reported by UpstreamError.
+const (
+	// ErrorTimeout counts round trips that hit a deadline.
+	ErrorTimeout = "timeout"
+	// ErrorServer counts upstream answers with a 5xx status.
+	ErrorServer = "5xx"
+	// ErrorNetwork counts round trips that failed before a response arrived.
+	ErrorNetwork = "network"
+)
+
+// Event stream sources reported by StreamOpened.
+const (
+	// StreamLocal is the heartbeat stream served by the proxy itself.
+	StreamLocal = "local"
+	// StreamUpstream is a relayed upstream GET stream.
+	StreamUpstream = "upstream"
+	// StreamResponse is a POST answered with text/event-stream.
+	StreamResponse = "response"
+)
+
+// Circuit breaker states reported by SetCircuitState.
+const (
+	// CircuitClosed lets requests through to the upstream.
+	CircuitClosed = "closed"
+	// CircuitHalfOpen admits a single trial request.
+	CircuitHalfOpen = "half_open"
+	// CircuitOpen rejects requests without contacting the upstream.
+	CircuitOpen = "open"
+)
+
+// Rate limiting reasons reported by RateLimited.
+const (
+	// RateLimitClient is the per-client request limit.
+	RateLimitClient = "client"
+	// RateLimitMethod is a per-client limit on one JSON-RPC method.
+	RateLimitMethod = "method"
+	// RateLimitTool is a per-client limit on one tool.
+	RateLimitTool = "tool"
+	// RateLimitConcurrency is the cap on upstream requests in flight.
+	RateLimitConcurrency = "concurrency"
+)
+
+// Configuration reload results reported by ConfigReload.
+const (
+	// ReloadSuccess is a reload that was applied.
+	ReloadSuccess = "success"
+	// ReloadFailure is a reload rejected in favour of the running
+	// configuration.
+	ReloadFailure = "failure"
+)
+
+// Metrics holds the proxy's collectors and the registry that serves them.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests       *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	upstreamErrors *prometheus.CounterVec
+	retries        prometheus.Counter
+	circuitState   *prometheus.GaugeVec
+	circuitReject  prometheus.Counter
+	rateLimited    *prometheus.CounterVec
+	activeStreams  *prometheus.GaugeVec
+	receivedBytes  prometheus.Counter
+	sentBytes      prometheus.Counter
+	configReloads  *prometheus.CounterVec
+	lastReload     prometheus.Gauge
+}
+
+// New registers the proxy collectors, together with the Go runtime and
+// process collectors, on a dedicated registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_proxy_requests_total",
+			Help: "Requests handled by the proxy by HTTP status and JSON-RPC method.",
+		}, []string{"status", "rpc_method"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mcp_proxy_request_duration_seconds",
+			Help:    "Time to serve a request, including streamed responses, by HTTP status and JSON-RPC method.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+		}, []string{"status", "rpc_method"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_proxy_upstream_errors_total",
+			Help: "Failed upstream round trips by class: timeout, 5xx or network.",
+		}, []string{"class"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mcp_proxy_upstream_retries_total",
+			Help: "Upstream attempts repeated by the retry policy.",
+		}),
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mcp_proxy_circuit_breaker_state",
+			Help: "Upstream circuit breaker state: 1 for the current state, 0 otherwise.",
+		}, []string{"state"}),
+		circuitReject: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mcp_proxy_circuit_breaker_rejected_total",
+			Help: "Requests failed fast because the upstream circuit was open.",
+		}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_proxy_rate_limited_total",
+			Help: "Requests refused with 429 by reason: client, method, tool or concurrency.",
+		}, []string{"reason"}),
+		activeStreams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mcp_proxy_active_sse_streams",
+			Help: "Server-Sent Events streams currently open to clients by source.",
+		}, []string{"source"}),
+		receivedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mcp_proxy_received_bytes_total",
+			Help: "Request body bytes received from clients.",
+		}),
+		sentBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mcp_proxy_sent_bytes_total",
+			Help: "Response body bytes sent to clients.",
+		}),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_proxy_config_reloads_total",
+			Help: "Configuration reload attempts by result: success or failure.",
+		}, []string{"result"}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mcp_proxy_config_last_reload_success_timestamp_seconds",
+			Help: "Unix time of the last configuration reload that was applied.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.upstreamErrors,
+		m.retries,
+		m.circuitState,
+		m.circuitReject,
+		m.rateLimited,
+		m.activeStreams,
+		m.receivedBytes,
+		m.sentBytes,
+		m.configReloads,
+		m.lastReload,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	// Expose every label combination that dashboards alert on, even before
+	// the first occurrence.
+	for _, class := range []string{ErrorTimeout, ErrorServer, ErrorNetwork} {
+		m.upstreamErrors.WithLabelValues(class)
+	}
+	for _, state := range []string{CircuitClosed, CircuitHalfOpen, CircuitOpen} {
+		m.circuitState.WithLabelValues(state)
+	}
+	for _, reason := range []string{RateLimitClient, RateLimitMethod, RateLimitTool, RateLimitConcurrency} {
+		m.rateLimited.WithLabelValues(reason)
+	}
+	for _, source := range []string{StreamLocal, StreamUpstream, StreamResponse} {
+		m.activeStreams.WithLabelValues(source)
+	}
+	for _, result := range []string{ReloadSuccess, ReloadFailure} {
+		m.configReloads.WithLabelValues(result)
+	}
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a finished request.
+func (m *Metrics) ObserveRequest(status int, rpcMethod string, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(code, rpcMethod).Inc()
+	m.duration.WithLabelValues(code, rpcMethod).Observe(elapsed.Seconds())
+}
+
+// UpstreamError counts a failed upstream round trip of the given class.
+func (m *Metrics) UpstreamError(class string) {
+	m.upstreamErrors.WithLabelValues(class).Inc()
+}
+
+// UpstreamRetry counts an upstream attempt repeated after a failure.
+func (m *Metrics) UpstreamRetry() {
+	m.retries.Inc()
+}
+
+// SetCircuitState marks state as the circuit breaker's current state.
+func (m *Metrics) SetCircuitState(state string) {
+	for _, s := range []string{CircuitClosed, CircuitHalfOpen, CircuitOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.circuitState.WithLabelValues(s).Set(value)
+	}
+}
+
+// CircuitRejected counts a request failed fast by the open circuit.
+func (m *Metrics) CircuitRejected() {
+	m.circuitReject.Inc()
+}
+
+// RateLimited counts a request refused for the given RateLimit* reason.
+func (m *Metrics) RateLimited(reason string) {
+	m.rateLimited.WithLabelValues(reason).Inc()
+}
+
+// StreamOpened marks an event stream from source as active and returns the
+// function that marks it closed.
+func (m *Metrics) StreamOpened(source string) func() {
+	gauge := m.activeStreams.WithLabelValues(source)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// AddReceivedBytes counts request body bytes read from a client.
+func (m *Metrics) AddReceivedBytes(n int) {
+	m.receivedBytes.Add(float64(n))
+}
+
+// AddSentBytes counts response body bytes written to a client.
+func (m *Metrics) AddSentBytes(n int) {
+	m.sentBytes.Add(float64(n))
+}
+
+// ConfigReload counts a configuration reload with the given Reload* result.
+func (m *Metrics) ConfigReload(result string) {
+	m.configReloads.WithLabelValues(result).Inc()
+	if result == ReloadSuccess {
+		m.lastReload.SetToCurrentTime()
+	}
+}
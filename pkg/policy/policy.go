@@ -0,0 +1,155 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package policy decides which MCP tools a client may call through the proxy.
+// A policy is a JSON document listing allowed and denied tool names or
+// path.Match glob patterns, plus optional constraints on string arguments:
+//
+//	{
+//	  "allow": ["search", "fs_*"],
+//	  "deny": ["fs_delete"],
+//	  "arguments": {
+//	    "fs_*": {"path": ["/workspace/*", "/tmp/*"]}
+//	  }
+//	}
+//
+// Deny rules win over allow rules. An empty allow list permits every tool
+// that is not denied. Argument constraints apply to every tool matching the
+// key pattern; a constrained argument, when present, must be a string that
+// matches at least one of the listed patterns.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+// Policy is an immutable set of tool rules.
+type Policy struct {
+	allow     []string
+	deny      []string
+	arguments map[string]map[string][]string
+}
+
+// document is the on-disk representation of a Policy.
+type document struct {
+	Allow     []string                       `json:"allow"`
+	Deny      []string                       `json:"deny"`
+	Arguments map[string]map[string][]string `json:"arguments"`
+}
+
+// Load reads and parses the policy file at path.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tool policy: %w", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse tool policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes a JSON policy document and validates its patterns.
+func Parse(data []byte) (*Policy, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	patterns := append(append([]string(nil), doc.Allow...), doc.Deny...)
+	for tool, args := range doc.Arguments {
+		patterns = append(patterns, tool)
+		for _, allowed := range args {
+			patterns = append(patterns, allowed...)
+		}
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &Policy{
+		allow:     doc.Allow,
+		deny:      doc.Deny,
+		arguments: doc.Arguments,
+	}, nil
+}
+
+// ToolAllowed reports whether the tool may be listed and called at all,
+// ignoring argument constraints.
+func (p *Policy) ToolAllowed(name string) bool {
+	if matchAny(p.deny, name) {
+		return false
+	}
+	return len(p.allow) == 0 || matchAny(p.allow, name)
+}
+
+// CheckCall returns an error describing why a tools/call with the given
+// arguments object is refused, or nil when it is permitted.
+func (p *Policy) CheckCall(name string, arguments json.RawMessage) error {
+	if !p.ToolAllowed(name) {
+		return fmt.Errorf("tool %q is not permitted", name)
+	}
+
+	var constraints []map[string][]string
+	for _, pattern := range sortedKeys(p.arguments) {
+		if ok, _ := path.Match(pattern, name); ok {
+			constraints = append(constraints, p.arguments[pattern])
+		}
+	}
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	var args map[string]json.RawMessage
+	if len(arguments) > 0 && string(arguments) != "null" {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return errors.New("tool arguments must be an object")
+		}
+	}
+
+	for _, constraint := range constraints {
+		for _, arg := range sortedKeys(constraint) {
+			raw, ok := args[arg]
+			if !ok {
+				continue
+			}
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("argument %q of tool %q must be a string", arg, name)
+			}
+			if !matchAny(constraint[arg], value) {
+				return fmt.Errorf("argument %q of tool %q is not permitted", arg, name)
+			}
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in a stable order so violations are
+// reported deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyCheckCall(t *testing.T) {
+	p, err := Parse([]byte(`{
+		"allow": ["search", "fs_*"],
+		"deny": ["fs_delete"],
+		"arguments": {"fs_*": {"path": ["/workspace/*"]}}
+	}`))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    string
+		allowed bool
+	}{
+		{name: "exact allow", tool: "search", args: `{"q":"x"}`, allowed: true},
+		{name: "glob allow", tool: "fs_read", args: `{"path":"/workspace/a.txt"}`, allowed: true},
+		{name: "deny wins over allow", tool: "fs_delete", args: `{"path":"/workspace/a.txt"}`, allowed: false},
+		{name: "not in allow list", tool: "shell", args: `{}`, allowed: false},
+		{name: "argument outside constraint", tool: "fs_read", args: `{"path":"/etc/passwd"}`, allowed: false},
+		{name: "argument of wrong type", tool: "fs_read", args: `{"path":["/workspace/a"]}`, allowed: false},
+		{name: "constrained argument absent", tool: "fs_list", args: `{}`, allowed: true},
+		{name: "arguments not an object", tool: "fs_read", args: `"oops"`, allowed: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.CheckCall(tc.tool, json.RawMessage(tc.args))
+			if tc.allowed && err != nil {
+				t.Fatalf("expected call to be allowed, got %v", err)
+			}
+			if !tc.allowed && err == nil {
+				t.Fatal("expected call to be denied")
+			}
+		})
+	}
+}
+
+func TestPolicyWithoutAllowListPermitsUndeniedTools(t *testing.T) {
+	p, err := Parse([]byte(`{"deny": ["admin_*"]}`))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	if !p.ToolAllowed("search") {
+		t.Fatal("expected tool outside deny list to be allowed")
+	}
+	if p.ToolAllowed("admin_reset") {
+		t.Fatal("expected denied tool to be refused")
+	}
+}
+
+func TestLoadRejectsInvalidPolicies(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"malformed.json":   `{"allow": [`,
+		"bad-pattern.json": `{"deny": ["[a-"]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write policy: %v", err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Fatalf("expected %s to be rejected", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected missing file to be rejected")
+	}
+}
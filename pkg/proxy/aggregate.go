@@ -0,0 +1,709 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+const (
+	// maxAggregateBody bounds each upstream answer read by the aggregator.
+	maxAggregateBody = 8 << 20
+	// maxAggregatePages bounds the list pages fetched from one upstream.
+	maxAggregatePages = 32
+)
+
+// aggregateLists maps the list methods merged across upstreams to the result
+// member holding their items.
+var aggregateLists = map[string]string{
+	"tools/list":     "tools",
+	"resources/list": "resources",
+	"prompts/list":   "prompts",
+}
+
+// aggregateCapabilities are the server capabilities advertised when at least
+// one upstream offers them.
+var aggregateCapabilities = []string{"tools", "resources", "prompts"}
+
+// aggregator serves one MCP endpoint backed by several upstreams. It keeps
+// the session each upstream issued behind a single proxy-issued id.
+type aggregator struct {
+	// path is the local path of the aggregated endpoint.
+	path string
+	// members lists the upstreams in configuration order, default first.
+	members []*upstream
+	// byPrefix holds the members by descending name prefix length so the
+	// most specific prefix owns a name.
+	byPrefix []*upstream
+
+	mu sync.Mutex
+	// sessions maps client-facing session ids to the session each member
+	// issued on initialize; stateless members have no entry.
+	sessions map[string]map[*upstream]string
+	// resources remembers which member listed each resource URI.
+	resources map[string]*upstream
+}
+
+// newAggregator returns an aggregator serving path from members.
+func newAggregator(path string, members []*upstream) *aggregator {
+	byPrefix := slices.Clone(members)
+	slices.SortStableFunc(byPrefix, func(a, b *upstream) int {
+		return len(b.cfg.AggregatePrefix) - len(a.cfg.AggregatePrefix)
+	})
+	return &aggregator{
+		path:      path,
+		members:   members,
+		byPrefix:  byPrefix,
+		sessions:  make(map[string]map[*upstream]string),
+		resources: make(map[string]*upstream),
+	}
+}
+
+// memberPath is the local path that reaches member's MCP endpoint once its
+// route prefix is mapped.
+func (a *aggregator) memberPath(member *upstream) string {
+	return member.prefix + a.path
+}
+
+// owner returns the member whose prefix starts name, and name without it.
+func (a *aggregator) owner(name string) (*upstream, string, bool) {
+	for _, member := range a.byPrefix {
+		prefix := member.cfg.AggregatePrefix
+		if len(name) > len(prefix) && strings.HasPrefix(name, prefix) {
+			return member, strings.TrimPrefix(name, prefix), true
+		}
+	}
+	return nil, "", false
+}
+
+// resourceOwner returns the member that listed uri, falling back to the
+// default upstream for resources never listed.
+func (a *aggregator) resourceOwner(uri string) *upstream {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if member, ok := a.resources[uri]; ok {
+		return member
+	}
+	return a.members[0]
+}
+
+// rememberResources records member as the owner of the listed resources.
+func (a *aggregator) rememberResources(member *upstream, items []json.RawMessage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, item := range items {
+		var resource struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(item, &resource); err == nil && resource.URI != "" {
+			a.resources[resource.URI] = member
+		}
+	}
+}
+
+// session returns the member sessions behind clientID. An empty id has no
+// sessions; an id the proxy never issued is reported with false.
+func (a *aggregator) session(clientID string) (map[*upstream]string, bool) {
+	if clientID == "" {
+		return nil, true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids, ok := a.sessions[clientID]
+	return ids, ok
+}
+
+// addSession stores the member sessions of a new client session and returns
+// its id.
+func (a *aggregator) addSession(ids map[*upstream]string) string {
+	clientID := newSessionID()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions[clientID] = ids
+	return clientID
+}
+
+// removeSession forgets clientID and returns its member sessions.
+func (a *aggregator) removeSession(clientID string) (map[*upstream]string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids, ok := a.sessions[clientID]
+	delete(a.sessions, clientID)
+	return ids, ok
+}
+
+// drain removes and returns every client session.
+func (a *aggregator) drain() map[string]map[*upstream]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sessions := a.sessions
+	a.sessions = make(map[string]map[*upstream]string)
+	return sessions
+}
+
+// serveAggregate answers a request to the aggregated endpoint. GET opens the
+// local heartbeat stream, since server-initiated messages of the upstreams
+// are not merged, and DELETE ends the session on every upstream. A single
+// tools/call, prompts/get or resources/read is relayed from its owner as is;
+// everything else is answered with merged results.
+func (p *Proxy) serveAggregate(w http.ResponseWriter, r *http.Request, payload []byte, event zerolog.Logger, start time.Time) {
+	switch r.Method {
+	case http.MethodGet:
+		p.serveEventStream(w, r, event, nil)
+		return
+	case http.MethodDelete:
+		p.endAggregateSession(w, r, event, start)
+		return
+	case http.MethodPost:
+	default:
+		writeLocalError(w, r, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID := r.Header.Get(headerMCPSessionID)
+	sessions, ok := p.aggregator.session(clientID)
+	if !ok {
+		p.writeForwardError(w, r, errUnknownSession, event, start)
+		return
+	}
+
+	// A routed call reaches one member; anything else may be fanned out to
+	// all of them at once, so it holds a slot for each.
+	member, body, routed := p.aggregator.routeSingle(payload)
+	slots := len(p.aggregator.members)
+	if routed {
+		slots = 1
+	}
+	release, ok := p.acquireUpstream(slots)
+	if !ok {
+		p.writeRateLimited(w, r, payload, metrics.RateLimitConcurrency, time.Second, event, start)
+		return
+	}
+	defer release()
+
+	if routed {
+		p.relayAggregateCall(w, r, member, sessions[member], body, payload, event, start)
+		return
+	}
+
+	reply, issued := p.aggregate(r, sessions, payload, event)
+	if issued != "" {
+		w.Header().Set(headerMCPSessionID, issued)
+	}
+	writeLocalRPC(w, reply)
+	logRPCErrors(event.Info(), rpcErrorCodes(reply)).
+		Dur("duration", time.Since(start)).
+		Msg("request aggregated")
+}
+
+// routeSingle resolves the owner of a payload holding exactly one routed
+// request. It returns the member and the request rewritten for it.
+func (a *aggregator) routeSingle(payload []byte) (*upstream, []byte, bool) {
+	msgs, batch := decodeRPC(payload)
+	if batch || len(msgs) != 1 || !msgs[0].isRequest() {
+		return nil, nil, false
+	}
+	member, body, err := a.route(&msgs[0], payload)
+	if member == nil || err != nil {
+		return nil, nil, false
+	}
+	return member, body, true
+}
+
+// route returns the member owning a tools/call, prompts/get or
+// resources/read request and the request rewritten for it. Other methods
+// yield a nil member; a name no member owns yields an error.
+func (a *aggregator) route(msg *rpcMessage, raw []byte) (*upstream, []byte, error) {
+	var params rpcCallParams
+	switch msg.Method {
+	case "tools/call", "prompts/get":
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, nil, fmt.Errorf("%s params must be an object", msg.Method)
+		}
+		member, name, ok := a.owner(params.Name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown name %q", params.Name)
+		}
+		body, err := rewriteParam(raw, "name", name)
+		return member, body, err
+	case "resources/read":
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, nil, fmt.Errorf("%s params must be an object", msg.Method)
+		}
+		return a.resourceOwner(params.URI), raw, nil
+	}
+	return nil, nil, nil
+}
+
+// relayAggregateCall forwards a routed request to its owner and relays the
+// answer, streamed or not, like any proxied response.
+func (p *Proxy) relayAggregateCall(w http.ResponseWriter, r *http.Request, member *upstream, sessionID string, body, payload []byte, event zerolog.Logger, start time.Time) {
+	req := p.memberRequest(r, member, sessionID, body)
+	event = event.With().Str("route", member.name).Logger()
+
+	resp, err := p.sendMember(req, event)
+	if errors.Is(err, errCircuitOpen) {
+		p.writeCircuitOpen(w, req, payload, event, start)
+		return
+	}
+	if err != nil {
+		p.writeForwardError(w, r, err, event, start)
+		return
+	}
+	// The client knows the aggregated session, not the member's.
+	resp.Header.Del(headerMCPSessionID)
+	p.writeResponse(w, req, resp, event, start)
+}
+
+// aggregate answers every message of payload and returns the encoded reply,
+// nil when nothing needs an answer, along with the session id issued on
+// initialize. Tool lists are filtered by the tool policy.
+func (p *Proxy) aggregate(r *http.Request, sessions map[*upstream]string, payload []byte, event zerolog.Logger) ([]byte, string) {
+	reqID := requestID(r.Context())
+	var raws []json.RawMessage
+	batch := len(bytes.TrimSpace(payload)) > 0 && bytes.TrimSpace(payload)[0] == '['
+	if batch {
+		if err := json.Unmarshal(payload, &raws); err != nil {
+			return newRPCError(nil, rpcCodeInvalidRequest, "invalid JSON-RPC payload", reqID), ""
+		}
+	} else {
+		raws = []json.RawMessage{payload}
+	}
+
+	var (
+		replies []json.RawMessage
+		issued  string
+	)
+	for _, raw := range raws {
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			replies = append(replies, newRPCError(nil, rpcCodeInvalidRequest, "invalid JSON-RPC message", reqID))
+			continue
+		}
+		if !msg.isRequest() {
+			if msg.Method != "" {
+				// Notifications concern every upstream's session.
+				p.fanOut(r, sessions, raw, event)
+			}
+			continue
+		}
+
+		var reply []byte
+		switch {
+		case msg.Method == "initialize":
+			reply, issued = p.aggregateInitialize(r, &msg, raw, event)
+			sessions, _ = p.aggregator.session(issued)
+		case msg.Method == "ping":
+			reply = newRPCResult(msg.ID, struct{}{})
+		case aggregateLists[msg.Method] != "":
+			reply = p.aggregateList(r, sessions, &msg, event)
+		default:
+			reply = p.aggregateCall(r, sessions, &msg, raw, event)
+		}
+		replies = append(replies, reply)
+	}
+
+	var reply []byte
+	switch {
+	case len(replies) == 0:
+		return nil, issued
+	case !batch:
+		reply = replies[0]
+	default:
+		encoded, err := json.Marshal(replies)
+		if err != nil {
+			return newRPCError(nil, rpcCodeInternalError, "encode batch failed", reqID), issued
+		}
+		reply = encoded
+	}
+	reply, _ = p.filterToolList(reply, toolListIDs(payload))
+	return reply, issued
+}
+
+// aggregateInitialize initializes every member and merges their answers: the
+// oldest protocol version wins and a capability is advertised when any member
+// offers it. The member sessions are stored under a new client session.
+func (p *Proxy) aggregateInitialize(r *http.Request, msg *rpcMessage, raw []byte, event zerolog.Logger) ([]byte, string) {
+	replies := p.fanOut(r, nil, raw, event)
+
+	var (
+		version  string
+		caps     = make(map[string]struct{})
+		ids      = make(map[*upstream]string)
+		answered = 0
+	)
+	for i, reply := range replies {
+		if reply.err != nil {
+			continue
+		}
+		var result struct {
+			ProtocolVersion string                     `json:"protocolVersion"`
+			Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		}
+		if err := decodeRPCResult(reply.payload, &result); err != nil {
+			event.Warn().Err(err).Str("route", p.aggregator.members[i].name).Msg("aggregated upstream failed to initialize")
+			continue
+		}
+		answered++
+		if version == "" || result.ProtocolVersion < version {
+			version = result.ProtocolVersion
+		}
+		for _, name := range aggregateCapabilities {
+			if _, ok := result.Capabilities[name]; ok {
+				caps[name] = struct{}{}
+			}
+		}
+		if reply.session != "" {
+			ids[p.aggregator.members[i]] = reply.session
+		}
+	}
+	if answered == 0 {
+		return newRPCError(msg.ID, rpcCodeUpstreamUnavailable, "no upstream answered initialize", requestID(r.Context())), ""
+	}
+
+	return newRPCResult(msg.ID, map[string]any{
+		"protocolVersion": version,
+		"capabilities":    caps,
+		"serverInfo": map[string]string{
+			"name":    p.cfg.ServiceName,
+			"version": buildVersion(),
+		},
+	}), p.aggregator.addSession(ids)
+}
+
+// aggregateList fetches every page of a list from each member and merges the
+// items. Tool and prompt names gain their member's prefix; resources keep
+// their URIs, which are remembered to route resources/read.
+func (p *Proxy) aggregateList(r *http.Request, sessions map[*upstream]string, msg *rpcMessage, event zerolog.Logger) []byte {
+	key := aggregateLists[msg.Method]
+	members := p.aggregator.members
+	lists := make([][]json.RawMessage, len(members))
+	errs := make([]error, len(members))
+
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], errs[i] = p.listMember(r, member, sessions[member], msg.Method, key, event)
+		}()
+	}
+	wg.Wait()
+
+	items := []json.RawMessage{}
+	answered := 0
+	for i, member := range members {
+		if errs[i] != nil {
+			event.Warn().Err(errs[i]).Str("route", member.name).Msg("aggregated upstream failed to list")
+			continue
+		}
+		answered++
+		if key == "resources" {
+			p.aggregator.rememberResources(member, lists[i])
+		} else {
+			prefixNames(lists[i], member.cfg.AggregatePrefix)
+		}
+		items = append(items, lists[i]...)
+	}
+	if answered == 0 {
+		return newRPCError(msg.ID, rpcCodeUpstreamUnavailable, "no upstream answered "+msg.Method, requestID(r.Context()))
+	}
+	return newRPCResult(msg.ID, map[string][]json.RawMessage{key: items})
+}
+
+// listMember follows the list pages of one member until it reports no
+// further cursor.
+func (p *Proxy) listMember(r *http.Request, member *upstream, sessionID, method, key string, event zerolog.Logger) ([]json.RawMessage, error) {
+	var (
+		items  []json.RawMessage
+		cursor string
+	)
+	for page := 0; page < maxAggregatePages; page++ {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		body, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      fmt.Sprintf("aggregate-%d", page),
+			"method":  method,
+			"params":  params,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reply, _, err := p.callMember(r, member, sessionID, body, event)
+		if err != nil {
+			return nil, err
+		}
+
+		var result map[string]json.RawMessage
+		if err := decodeRPCResult(reply, &result); err != nil {
+			return nil, err
+		}
+		var list []json.RawMessage
+		if raw, ok := result[key]; ok {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+		}
+		items = append(items, list...)
+
+		cursor = ""
+		if raw, ok := result["nextCursor"]; ok {
+			_ = json.Unmarshal(raw, &cursor)
+		}
+		if cursor == "" {
+			return items, nil
+		}
+	}
+	event.Warn().Str("route", member.name).Str("rpc_method", method).Msg("aggregated list truncated after too many pages")
+	return items, nil
+}
+
+// aggregateCall answers one request inside a batch, or one no member owns,
+// by sending it to its owner.
+func (p *Proxy) aggregateCall(r *http.Request, sessions map[*upstream]string, msg *rpcMessage, raw []byte, event zerolog.Logger) []byte {
+	reqID := requestID(r.Context())
+	member, body, err := p.aggregator.route(msg, raw)
+	if err != nil {
+		return newRPCError(msg.ID, rpcCodeInvalidParams, err.Error(), reqID)
+	}
+	if member == nil {
+		return newRPCError(msg.ID, rpcCodeMethodNotFound, msg.Method+" is not supported by the aggregated endpoint", reqID)
+	}
+
+	reply, _, err := p.callMember(r, member, sessions[member], body, event)
+	if err != nil {
+		event.Warn().Err(err).Str("route", member.name).Msg("aggregated upstream request failed")
+		return newRPCError(msg.ID, rpcCodeUpstreamUnavailable, "upstream request failed", reqID)
+	}
+	return reply
+}
+
+// memberReply is one member's answer to a fanned out message.
+type memberReply struct {
+	payload []byte
+	session string
+	err     error
+}
+
+// fanOut sends raw to every member concurrently and returns the answers in
+// member order. Failures are logged and reported in the answer.
+func (p *Proxy) fanOut(r *http.Request, sessions map[*upstream]string, raw []byte, event zerolog.Logger) []memberReply {
+	members := p.aggregator.members
+	replies := make([]memberReply, len(members))
+
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply := &replies[i]
+			reply.payload, reply.session, reply.err = p.callMember(r, member, sessions[member], raw, event)
+			if reply.err != nil {
+				event.Warn().Err(reply.err).Str("route", member.name).Msg("aggregated upstream request failed")
+			}
+		}()
+	}
+	wg.Wait()
+	return replies
+}
+
+// callMember sends one JSON-RPC message to member and returns its answer,
+// nil for notifications, and the session id the member issued, if any. An
+// event stream answer is read until the response to the message arrives.
+func (p *Proxy) callMember(r *http.Request, member *upstream, sessionID string, body []byte, event zerolog.Logger) ([]byte, string, error) {
+	resp, err := p.sendMember(p.memberRequest(r, member, sessionID, body), event)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			event.Error().Err(err).Msg("close upstream response body failed")
+		}
+	}()
+
+	issued := resp.Header.Get(headerMCPSessionID)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, issued, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+
+	var msg rpcEnvelope
+	if err := json.Unmarshal(body, &msg); err != nil || !msg.isRequest() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return nil, issued, nil
+	}
+
+	if isEventStreamResponse(resp) {
+		markStreaming(resp, member.cfg.StreamIdleTimeout)
+		reader := bufio.NewReader(io.LimitReader(resp.Body, maxAggregateBody))
+		for {
+			sse, err := readSSEEvent(reader)
+			if err != nil {
+				return nil, issued, fmt.Errorf("read upstream event stream: %w", err)
+			}
+			var reply rpcEnvelope
+			if sse.isMessage() && json.Unmarshal([]byte(sse.Data), &reply) == nil &&
+				reply.Method == "" && bytes.Equal(reply.ID, msg.ID) {
+				return []byte(sse.Data), issued, nil
+			}
+		}
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, maxAggregateBody))
+	if err != nil {
+		return nil, issued, fmt.Errorf("read upstream response: %w", err)
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil, issued, errors.New("upstream sent no response")
+	}
+	return payload, issued, nil
+}
+
+// memberRequest builds the request for member from the inbound one: body
+// replaces the client's payload and the member's own session id replaces the
+// aggregated one.
+func (p *Proxy) memberRequest(r *http.Request, member *upstream, sessionID string, body []byte) *http.Request {
+	req := r.Clone(context.WithValue(r.Context(), upstreamKey{}, member))
+	req.URL.Path = p.aggregator.memberPath(member)
+	req.URL.RawPath = ""
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Del(headerMCPSessionID)
+	if sessionID != "" {
+		req.Header.Set(headerMCPSessionID, sessionID)
+	}
+	return req
+}
+
+// sendMember sends req, signed for its member, within a client span.
+func (p *Proxy) sendMember(req *http.Request, event zerolog.Logger) (*http.Response, error) {
+	req, span := p.startClientSpan(req)
+	resp, err := p.sendRequest(req, event)
+	if err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+	endSpan(span, resp.StatusCode)
+	resp.Header.Del(headerRequestID)
+	return resp, nil
+}
+
+// endAggregateSession answers a DELETE by ending the client's session on
+// every member that issued one.
+func (p *Proxy) endAggregateSession(w http.ResponseWriter, r *http.Request, event zerolog.Logger, start time.Time) {
+	clientID := r.Header.Get(headerMCPSessionID)
+	ids, ok := p.aggregator.removeSession(clientID)
+	if !ok {
+		p.writeForwardError(w, r, errUnknownSession, event, start)
+		return
+	}
+	p.endAggregateMembers(r.Context(), clientID, ids)
+	w.WriteHeader(http.StatusNoContent)
+	event.Info().Dur("duration", time.Since(start)).Msg("aggregated session ended")
+}
+
+// endAggregateMembers sends a DELETE for each member session in parallel.
+func (p *Proxy) endAggregateMembers(ctx context.Context, clientID string, ids map[*upstream]string) {
+	var wg sync.WaitGroup
+	for member, upstreamID := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.endSession(context.WithValue(ctx, upstreamKey{}, member), &mcpSession{
+				clientID:   clientID,
+				upstreamID: upstreamID,
+				path:       p.aggregator.memberPath(member),
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// decodeRPCResult decodes the result of a JSON-RPC response into v and
+// reports error responses as errors.
+func decodeRPCResult(payload []byte, v any) error {
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcErrorObject `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return fmt.Errorf("decode upstream response: %w", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("upstream error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		return fmt.Errorf("decode upstream result: %w", err)
+	}
+	return nil
+}
+
+// rewriteParam returns the JSON-RPC message raw with params[field] set to
+// value. A message checkRPCKeys rejects is refused rather than rewritten, as
+// the rewrite would otherwise keep only one of its competing members.
+func rewriteParam(raw []byte, field, value string) ([]byte, error) {
+	if err := checkRPCKeys(raw); err != nil {
+		return nil, err
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	}
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		return nil, err
+	}
+	var err error
+	if params[field], err = json.Marshal(value); err != nil {
+		return nil, err
+	}
+	if msg["params"], err = json.Marshal(params); err != nil {
+		return nil, err
+	}
+	return json.Marshal(msg)
+}
+
+// prefixNames prepends prefix to the name of every item in place.
+func prefixNames(items []json.RawMessage, prefix string) {
+	if prefix == "" {
+		return
+	}
+	for i, item := range items {
+		var obj map[string]json.RawMessage
+		var name string
+		if json.Unmarshal(item, &obj) != nil || json.Unmarshal(obj["name"], &name) != nil {
+			continue
+		}
+		obj["name"], _ = json.Marshal(prefix + name)
+		if encoded, err := json.Marshal(obj); err == nil {
+			items[i] = encoded
+		}
+	}
+}
+
+// buildVersion reports the module version the binary was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,365 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/policy"
+)
+
+// fakeMCPServer is a minimal MCP server that lists one tool per page and
+// records the requests it received.
+type fakeMCPServer struct {
+	name  string
+	tools []string
+	// sse answers requests with an event stream instead of JSON.
+	sse bool
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+// recordedRequest is what fakeMCPServer keeps of a request.
+type recordedRequest struct {
+	method string
+	path   string
+	header http.Header
+	rpc    rpcMessage
+	params rpcCallParams
+}
+
+func (s *fakeMCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var msg rpcMessage
+	_ = json.Unmarshal(body, &msg)
+	var params rpcCallParams
+	_ = json.Unmarshal(msg.Params, &params)
+	var cursor struct {
+		Cursor string `json:"cursor"`
+	}
+	_ = json.Unmarshal(msg.Params, &cursor)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, recordedRequest{method: r.Method, path: r.URL.Path, header: r.Header.Clone(), rpc: msg, params: params})
+	s.mu.Unlock()
+
+	if r.Method == http.MethodDelete || !msg.isRequest() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var result any
+	switch msg.Method {
+	case "initialize":
+		w.Header().Set(headerMCPSessionID, s.name+"-session")
+		result = map[string]any{
+			"protocolVersion": "2025-06-18",
+			"capabilities":    map[string]any{"tools": map[string]any{}, "resources": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": "1"},
+		}
+	case "tools/list":
+		page, _ := strconv.Atoi(cursor.Cursor)
+		list := map[string]any{"tools": []map[string]string{{"name": s.tools[page]}}}
+		if page+1 < len(s.tools) {
+			list["nextCursor"] = strconv.Itoa(page + 1)
+		}
+		result = list
+	case "resources/list":
+		result = map[string]any{"resources": []map[string]string{{"uri": s.name + "://doc", "name": "doc"}}}
+	case "prompts/list":
+		result = map[string]any{"prompts": []map[string]string{}}
+	case "tools/call", "resources/read":
+		result = map[string]any{"content": []map[string]string{{"type": "text", "text": s.name + ":" + params.Name + params.URI}}}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reply := newRPCResult(msg.ID, result)
+	if s.sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\ndata: %s\n\n", reply)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(reply)
+}
+
+// received returns the requests recorded so far.
+func (s *fakeMCPServer) received() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest(nil), s.requests...)
+}
+
+func TestAggregatorOwner(t *testing.T) {
+	def := &upstream{cfg: config.Config{}}
+	gh := &upstream{name: "gh", cfg: config.Config{AggregatePrefix: "gh_"}}
+	ghx := &upstream{name: "ghx", cfg: config.Config{AggregatePrefix: "gh_x_"}}
+	a := newAggregator("/mcp", []*upstream{def, gh, ghx})
+
+	tests := []struct {
+		name     string
+		owner    *upstream
+		stripped string
+	}{
+		{name: "search", owner: def, stripped: "search"},
+		{name: "gh_issue", owner: gh, stripped: "issue"},
+		{name: "gh_x_run", owner: ghx, stripped: "run"},
+		{name: "gh_", owner: def, stripped: "gh_"},
+	}
+	for _, tc := range tests {
+		owner, stripped, ok := a.owner(tc.name)
+		if !ok || owner != tc.owner || stripped != tc.stripped {
+			t.Errorf("owner(%s) = %v, %q, %v", tc.name, owner, stripped, ok)
+		}
+	}
+
+	a = newAggregator("/mcp", []*upstream{gh})
+	if _, _, ok := a.owner("search"); ok {
+		t.Error("a name without any member prefix should have no owner")
+	}
+}
+
+func TestProxyAggregatesUpstreams(t *testing.T) {
+	local := &fakeMCPServer{name: "local", tools: []string{"search", "fetch"}}
+	localServer := httptest.NewServer(local)
+	defer localServer.Close()
+	github := &fakeMCPServer{name: "github", tools: []string{"create_issue"}, sse: true}
+	githubServer := httptest.NewServer(github)
+	defer githubServer.Close()
+
+	cfg := newStreamTestConfig(t, localServer.URL)
+	cfg.AggregatePath = "/mcp"
+	routeCfg := newStreamTestConfig(t, githubServer.URL)
+	routeCfg.AuthMode = auth.ModeBearer
+	routeCfg.BearerToken = "github-token"
+	routeCfg.AggregatePrefix = "github_"
+	cfg.Routes = []config.Route{{Name: "github", Prefix: "/github", Config: routeCfg}}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	session := ""
+	call := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://proxy/mcp", strings.NewReader(body))
+		if session != "" {
+			req.Header.Set(headerMCPSessionID, session)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	result := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if err := decodeRPCResult(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode %d %s: %v", rec.Code, rec.Body.String(), err)
+		}
+	}
+
+	rec := call(http.MethodPost, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	var init struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	}
+	result(rec, &init)
+	session = rec.Header().Get(headerMCPSessionID)
+	if session == "" || session == "local-session" || session == "github-session" {
+		t.Fatalf("aggregated session id %q", session)
+	}
+	if init.ProtocolVersion != "2025-06-18" || init.Capabilities["tools"] == nil || init.Capabilities["prompts"] != nil {
+		t.Fatalf("unexpected merged initialize %s", rec.Body.String())
+	}
+
+	if rec := call(http.MethodPost, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("notification answered %d", rec.Code)
+	}
+
+	var tools struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	result(call(http.MethodPost, testListRequest), &tools)
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "search,fetch,github_create_issue" {
+		t.Fatalf("merged tools %v", names)
+	}
+
+	var called struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	rec = call(http.MethodPost, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"github_create_issue"}}`)
+	if !strings.Contains(rec.Body.String(), "notifications/progress") {
+		t.Fatalf("routed call was not relayed as a stream: %s", rec.Body.String())
+	}
+	if got := rec.Header().Get(headerMCPSessionID); got != "" && got != session {
+		t.Fatalf("member session leaked to the client: %s", got)
+	}
+
+	rec = call(http.MethodPost, `[{"jsonrpc":"2.0","id":3,"method":"resources/list"},`+
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"search"}},`+
+		`{"jsonrpc":"2.0","id":5,"method":"completion/complete"}]`)
+	var batch []json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil || len(batch) != 3 {
+		t.Fatalf("unexpected batch reply %s", rec.Body.String())
+	}
+	if err := decodeRPCResult(batch[1], &called); err != nil || called.Content[0].Text != "local:search" {
+		t.Fatalf("batched call answered %s", batch[1])
+	}
+	if !strings.Contains(string(batch[2]), strconv.Itoa(rpcCodeMethodNotFound)) {
+		t.Fatalf("unsupported method answered %s", batch[2])
+	}
+
+	rec = call(http.MethodPost, `{"jsonrpc":"2.0","id":6,"method":"resources/read","params":{"uri":"github://doc"}}`)
+	if !strings.Contains(rec.Body.String(), "github:github://doc") {
+		t.Fatalf("resource read was not routed to its lister: %s", rec.Body.String())
+	}
+
+	if rec := call(http.MethodDelete, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE answered %d", rec.Code)
+	}
+	if rec := call(http.MethodPost, testListRequest); rec.Code != http.StatusNotFound {
+		t.Fatalf("ended session answered %d", rec.Code)
+	}
+
+	// Every upstream saw its own session and credentials at its MCP path.
+	for _, tc := range []struct {
+		server  *fakeMCPServer
+		session string
+		check   func(http.Header) bool
+	}{
+		{server: local, session: "local-session", check: func(h http.Header) bool { return h.Get(auth.HeaderSignature) != "" }},
+		{server: github, session: "github-session", check: func(h http.Header) bool { return h.Get("Authorization") == "Bearer github-token" }},
+	} {
+		requests := tc.server.received()
+		for i, req := range requests {
+			if req.path != "/mcp" || !tc.check(req.header) {
+				t.Fatalf("%s request %d: %s %s with %v", tc.server.name, i, req.method, req.path, req.header)
+			}
+			if req.rpc.Method != "initialize" && req.header.Get(headerMCPSessionID) != tc.session {
+				t.Fatalf("%s request %d (%s) carried session %q", tc.server.name, i, req.rpc.Method, req.header.Get(headerMCPSessionID))
+			}
+			if req.rpc.Method == "tools/call" && strings.HasPrefix(req.params.Name, "github_") {
+				t.Fatalf("%s received the prefixed tool name %s", tc.server.name, req.params.Name)
+			}
+		}
+		if last := requests[len(requests)-1]; last.method != http.MethodDelete {
+			t.Fatalf("%s session was not ended: last request %s %s", tc.server.name, last.method, last.rpc.Method)
+		}
+	}
+}
+
+func TestProxyAggregateFiltersToolsByPolicy(t *testing.T) {
+	local := &fakeMCPServer{name: "local", tools: []string{"search", "delete_repo"}}
+	server := httptest.NewServer(local)
+	defer server.Close()
+
+	cfg := newStreamTestConfig(t, server.URL)
+	cfg.AggregatePath = "/mcp"
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	toolPolicy, err := policy.Parse([]byte(`{"deny":["delete_*"]}`))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	p.policy.Store(toolPolicy)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testListRequest)))
+	if strings.Contains(rec.Body.String(), "delete_repo") || !strings.Contains(rec.Body.String(), "search") {
+		t.Fatalf("policy not applied to merged list: %s", rec.Body.String())
+	}
+}
+
+func TestProxyAggregateHoldsSlotPerMember(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	arrived := make(chan struct{}, 4)
+	gate := make(chan struct{})
+	member := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for old := peak.Load(); n > old && !peak.CompareAndSwap(old, n); old = peak.Load() {
+		}
+		arrived <- struct{}{}
+		<-gate
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(newRPCResult(msg.ID, map[string]any{"tools": []any{}}))
+	})
+	local := httptest.NewServer(member)
+	defer local.Close()
+	remote := httptest.NewServer(member)
+	defer remote.Close()
+	// Unblock the members before the servers close, even on failure.
+	release := sync.OnceFunc(func() { close(gate) })
+	defer release()
+
+	cfg := newStreamTestConfig(t, local.URL)
+	cfg.AggregatePath = "/mcp"
+	cfg.MaxConcurrentUpstream = 2
+	cfg.RequestTimeout = 10 * time.Second
+	routeCfg := newStreamTestConfig(t, remote.URL)
+	routeCfg.AggregatePrefix = "remote_"
+	routeCfg.RequestTimeout = cfg.RequestTimeout
+	cfg.Routes = []config.Route{{Name: "remote", Prefix: "/remote", Config: routeCfg}}
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	first := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testListRequest)))
+		first <- rec.Code
+	}()
+	<-arrived
+	<-arrived
+
+	second := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testListRequest)))
+		second <- rec.Code
+	}()
+	select {
+	case code := <-second:
+		if code != http.StatusTooManyRequests {
+			t.Fatalf("second aggregated list answered %d, want 429", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second aggregated list was admitted past the concurrency cap")
+	}
+	release()
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("first aggregated list answered %d", code)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("%d upstream requests in flight, cap is 2", got)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+// errCircuitOpen is returned without contacting the upstream while the
+// circuit breaker is open.
+var errCircuitOpen = &httpError{Status: http.StatusServiceUnavailable, Err: errors.New("upstream circuit open")}
+
+// rpcCodeUpstreamUnavailable is the implementation-defined JSON-RPC server
+// error sent while the circuit is open.
+const rpcCodeUpstreamUnavailable = -32000
+
+// breakerState is the state of the circuit breaker.
+type breakerState int
+
+const (
+	// breakerClosed lets every request through.
+	breakerClosed breakerState = iota
+	// breakerHalfOpen lets a single trial request through after the open
+	// period to test whether the upstream recovered.
+	breakerHalfOpen
+	// breakerOpen rejects requests until the open period ends.
+	breakerOpen
+)
+
+// String returns the state as used in metrics and the readiness report.
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return metrics.CircuitHalfOpen
+	case breakerOpen:
+		return metrics.CircuitOpen
+	default:
+		return metrics.CircuitClosed
+	}
+}
+
+// circuitBreaker stops sending requests to an upstream that keeps failing.
+// It opens after a run of consecutive failures or when the failure rate over
+// the last minRequests outcomes reaches errorRate.
+type circuitBreaker struct {
+	// consecutiveFailures opens the circuit after that many failures in a
+	// row; zero disables the trigger.
+	consecutiveFailures int
+	// errorRate opens the circuit when that share of the recent outcomes
+	// failed; zero disables the trigger.
+	errorRate float64
+	// openFor is how long the circuit stays open before a trial request.
+	openFor time.Duration
+	// now is the clock; tests replace it.
+	now func() time.Time
+	// onChange observes transitions; it is called with mu held.
+	onChange func(from, to breakerState)
+
+	mu       sync.Mutex
+	state    breakerState
+	openedAt time.Time
+	// trial is set while the half-open trial request is in flight.
+	trial bool
+	// consecutive counts failures since the last success.
+	consecutive int
+	// outcomes is a ring of recent results, true for failures; failed counts
+	// the failures in it.
+	outcomes []bool
+	next     int
+	filled   bool
+	failed   int
+}
+
+// newCircuitBreaker returns the breaker configured in cfg, or nil when both
+// triggers are disabled.
+func newCircuitBreaker(cfg config.Config, onChange func(from, to breakerState)) *circuitBreaker {
+	if cfg.BreakerFailures <= 0 && cfg.BreakerErrorRate <= 0 {
+		return nil
+	}
+	window := cfg.BreakerMinRequests
+	if window <= 0 {
+		window = 1
+	}
+	return &circuitBreaker{
+		consecutiveFailures: cfg.BreakerFailures,
+		errorRate:           cfg.BreakerErrorRate,
+		openFor:             cfg.BreakerOpenDuration,
+		now:                 time.Now,
+		onChange:            onChange,
+		outcomes:            make([]bool, window),
+	}
+}
+
+// allow reports whether a request may be sent. Once the open period has
+// passed it moves to half-open and admits exactly one trial request.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen && b.now().Sub(b.openedAt) >= b.openFor {
+		b.setStateLocked(breakerHalfOpen)
+	}
+	switch b.state {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// record reports the outcome of an admitted request.
+func (b *circuitBreaker) record(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		// A request admitted before the circuit opened; its verdict is stale.
+		return
+	case breakerHalfOpen:
+		b.trial = false
+		if failure {
+			b.openLocked()
+		} else {
+			b.resetLocked()
+			b.setStateLocked(breakerClosed)
+		}
+		return
+	}
+
+	if b.filled && b.outcomes[b.next] {
+		b.failed--
+	}
+	b.outcomes[b.next] = failure
+	b.next = (b.next + 1) % len(b.outcomes)
+	b.filled = b.filled || b.next == 0
+
+	if !failure {
+		b.consecutive = 0
+		return
+	}
+	b.failed++
+	b.consecutive++
+
+	tripped := b.consecutiveFailures > 0 && b.consecutive >= b.consecutiveFailures
+	if b.errorRate > 0 && b.filled && float64(b.failed)/float64(len(b.outcomes)) >= b.errorRate {
+		tripped = true
+	}
+	if tripped {
+		b.openLocked()
+	}
+}
+
+// release frees the half-open trial slot when the request ended without a
+// verdict, for example because the client went away.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.trial = false
+	}
+}
+
+// snapshot returns the current state and, when open, how long until a trial
+// request is admitted.
+func (b *circuitBreaker) snapshot() (breakerState, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerOpen {
+		return b.state, 0
+	}
+	return b.state, max(b.openFor-b.now().Sub(b.openedAt), 0)
+}
+
+// openLocked opens the circuit; b.mu must be held.
+func (b *circuitBreaker) openLocked() {
+	b.openedAt = b.now()
+	b.resetLocked()
+	b.setStateLocked(breakerOpen)
+}
+
+// resetLocked clears the recorded outcomes; b.mu must be held.
+func (b *circuitBreaker) resetLocked() {
+	b.consecutive = 0
+	b.failed = 0
+	b.next = 0
+	b.filled = false
+	clear(b.outcomes)
+}
+
+// setStateLocked transitions to state; b.mu must be held.
+func (b *circuitBreaker) setStateLocked(state breakerState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(from, state)
+	}
+}
+
+// writeCircuitOpen answers a request rejected by the open circuit. JSON-RPC
+// requests receive JSON-RPC errors so agents surface a tool failure at once;
+// anything else gets a plain 503. Both carry Retry-After.
+func (p *Proxy) writeCircuitOpen(w http.ResponseWriter, r *http.Request, payload []byte, event zerolog.Logger, start time.Time) {
+	_, wait := p.upstreamFor(r.Context()).breaker.snapshot()
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+
+	reply := rpcErrorReplies(payload, rpcCodeUpstreamUnavailable, "upstream unavailable: circuit breaker open", requestID(r.Context()))
+	if reply != nil {
+		writeLocalRPC(w, reply)
+	} else {
+		writeLocalError(w, r, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+	event.Warn().
+		Dur("duration", time.Since(start)).
+		Msg("request rejected by open circuit breaker")
+}
@@ -0,0 +1,245 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+func newTestBreaker(cfg config.Config) (*circuitBreaker, *time.Time, *[]string) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var transitions []string
+	b := newCircuitBreaker(cfg, func(from, to breakerState) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+	b.now = func() time.Time { return clock }
+	return b, &clock, &transitions
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b, clock, transitions := newTestBreaker(config.Config{
+		BreakerFailures:     3,
+		BreakerMinRequests:  10,
+		BreakerOpenDuration: 30 * time.Second,
+	})
+
+	for range 2 {
+		b.allow()
+		b.record(true)
+	}
+	b.allow()
+	b.record(false)
+	for range 2 {
+		b.allow()
+		b.record(true)
+	}
+	if state, _ := b.snapshot(); state != breakerClosed {
+		t.Fatalf("a success should reset the consecutive failures, got %s", state)
+	}
+	b.allow()
+	b.record(true)
+	if state, wait := b.snapshot(); state != breakerOpen || wait != 30*time.Second {
+		t.Fatalf("expected open for 30s, got %s for %v", state, wait)
+	}
+	if b.allow() {
+		t.Fatal("open circuit admitted a request")
+	}
+
+	*clock = clock.Add(30 * time.Second)
+	if !b.allow() {
+		t.Fatal("half-open circuit refused the trial request")
+	}
+	if b.allow() {
+		t.Fatal("half-open circuit admitted a second request during the trial")
+	}
+	b.record(true)
+	if state, _ := b.snapshot(); state != breakerOpen {
+		t.Fatalf("failed trial should reopen the circuit, got %s", state)
+	}
+
+	*clock = clock.Add(30 * time.Second)
+	b.allow()
+	b.release()
+	if !b.allow() {
+		t.Fatal("released trial slot was not reusable")
+	}
+	b.record(false)
+	if state, _ := b.snapshot(); state != breakerClosed {
+		t.Fatalf("successful trial should close the circuit, got %s", state)
+	}
+
+	want := "closed->open,open->half_open,half_open->open,open->half_open,half_open->closed"
+	if got := strings.Join(*transitions, ","); got != want {
+		t.Fatalf("transitions = %s, want %s", got, want)
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	b, _, _ := newTestBreaker(config.Config{
+		BreakerErrorRate:    0.5,
+		BreakerMinRequests:  4,
+		BreakerOpenDuration: time.Second,
+	})
+
+	for i, failure := range []bool{false, true, false} {
+		b.allow()
+		b.record(failure)
+		if state, _ := b.snapshot(); state != breakerClosed {
+			t.Fatalf("opened after %d outcomes, before the window filled", i+1)
+		}
+	}
+	b.allow()
+	b.record(true)
+	if state, _ := b.snapshot(); state != breakerOpen {
+		t.Fatalf("expected open at a 50%% error rate, got %s", state)
+	}
+}
+
+func TestProxyFailsFastWhileCircuitOpen(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "deploying", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.BreakerFailures = 2
+	cfg.BreakerMinRequests = 10
+	cfg.BreakerOpenDuration = time.Minute
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	call := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(body)))
+		return rec
+	}
+	for range 2 {
+		if rec := call(testCallRequest); rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected the upstream 503, got %d", rec.Code)
+		}
+	}
+
+	rec := call(testCallRequest)
+	if hits.Load() != 2 {
+		t.Fatalf("open circuit still reached the upstream: %d hits", hits.Load())
+	}
+	var reply rpcErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode fail-fast reply %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusOK || reply.Error.Code != rpcCodeUpstreamUnavailable || string(reply.ID) != "1" {
+		t.Fatalf("unexpected fail-fast reply %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	if rec := call(`{"jsonrpc":"2.0","method":"notifications/initialized"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("notification should get a plain 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathReadyz, nil))
+	check := decodeHealthReport(t, rec).Checks["circuit_breaker"]
+	if rec.Code != http.StatusServiceUnavailable || check.State != breakerOpen.String() {
+		t.Fatalf("readiness did not report the open circuit: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathMetrics, nil))
+	for _, want := range []string{
+		`mcp_proxy_circuit_breaker_state{state="open"} 1`,
+		`mcp_proxy_circuit_breaker_state{state="closed"} 0`,
+		`mcp_proxy_circuit_breaker_rejected_total 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestProxyFailsFastOnEventStreamWhileCircuitOpen(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "deploying", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SSEMode = config.SSEModeUpstream
+	cfg.BreakerFailures = 1
+	cfg.BreakerMinRequests = 10
+	cfg.BreakerOpenDuration = time.Minute
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testCallRequest)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the upstream 503, got %d", rec.Code)
+	}
+
+	stream := newFlushRecorder()
+	handler.ServeHTTP(stream, httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil))
+	if hits.Load() != 1 {
+		t.Fatalf("open circuit still reached the upstream: %d hits", hits.Load())
+	}
+	if stream.status != http.StatusServiceUnavailable || stream.Header().Get("Retry-After") != "60" {
+		t.Fatalf("unexpected fail-fast stream reply %d, Retry-After %q", stream.status, stream.Header().Get("Retry-After"))
+	}
+}
+
+func TestReadinessProbeBypassesCircuitBreaker(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "deploying", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.BreakerFailures = 2
+	cfg.BreakerOpenDuration = time.Minute
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	for range 5 {
+		if result := p.probeUpstream(context.Background()); result.Status != checkFailed {
+			t.Fatalf("probe of a failing upstream reported %s", result.Status)
+		}
+	}
+	if state, _ := p.breaker.snapshot(); state != breakerClosed {
+		t.Fatalf("probe failures moved the breaker to %s", state)
+	}
+
+	// An open circuit does not stop the probe from seeing the upstream.
+	for range 2 {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testCallRequest)))
+	}
+	before := hits.Load()
+	p.probeUpstream(context.Background())
+	if hits.Load() != before+1 {
+		t.Fatal("probe was rejected by the open circuit")
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+const (
+	// PathHealthz answers liveness probes; it only reports that the process
+	// is serving requests.
+	PathHealthz = "/healthz"
+	// PathReadyz answers readiness probes with the result of every check.
+	PathReadyz = "/readyz"
+
+	// defaultProbePath is where the MCP ping probe is posted when no probe
+	// path is configured.
+	defaultProbePath = "/mcp"
+
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkPending = "pending"
+)
+
+// checkResult describes one readiness check in the /readyz body.
+type checkResult struct {
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	HTTPStatus int        `json:"http_status,omitempty"`
+	LatencyMS  int64      `json:"latency_ms,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+	// State is the circuit breaker state for the circuit_breaker check.
+	State string `json:"state,omitempty"`
+}
+
+// healthReport is the JSON body served on the health endpoints.
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// readiness caches the outcome of the periodic upstream probe.
+type readiness struct {
+	mu       sync.RWMutex
+	upstream *checkResult
+}
+
+// readinessProbeKey marks probe requests in their context so they bypass the
+// circuit breaker; a failing probe must not trip it for client traffic.
+type readinessProbeKey struct{}
+
+// isReadinessProbe reports whether ctx belongs to a readiness probe.
+func isReadinessProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(readinessProbeKey{}).(bool)
+	return probe
+}
+
+// get returns the cached upstream result.
+func (r *readiness) get() *checkResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.upstream
+}
+
+// set replaces the cached upstream result.
+func (r *readiness) set(result checkResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upstream = &result
+}
+
+// isHealthPath reports whether path is answered by the local health handlers.
+func isHealthPath(path string) bool {
+	return path == PathHealthz || path == PathReadyz
+}
+
+// serveHealth answers liveness and readiness probes locally.
+func (p *Proxy) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == PathHealthz {
+		writeHealthReport(w, healthReport{Status: checkOK})
+		return
+	}
+
+	report := healthReport{Status: checkOK, Checks: make(map[string]checkResult)}
+
+	configCheck := checkResult{Status: checkOK}
+	if err := validateRuntimeConfig(p.cfg); err != nil {
+		configCheck = checkResult{Status: checkFailed, Error: err.Error()}
+	}
+	report.Checks["config"] = configCheck
+
+	if p.cfg.ReadyProbeInterval > 0 {
+		upstream := checkResult{Status: checkPending}
+		if cached := p.readiness.get(); cached != nil {
+			upstream = *cached
+		}
+		report.Checks["upstream"] = upstream
+	}
+
+	if p.breaker != nil {
+		state, _ := p.breaker.snapshot()
+		breakerCheck := checkResult{Status: checkOK, State: state.String()}
+		if state == breakerOpen {
+			breakerCheck.Status = checkFailed
+			breakerCheck.Error = "upstream circuit is open"
+		}
+		report.Checks["circuit_breaker"] = breakerCheck
+	}
+
+	for _, check := range report.Checks {
+		if check.Status != checkOK {
+			report.Status = checkFailed
+		}
+	}
+	writeHealthReport(w, report)
+}
+
+// writeHealthReport encodes report with 200 when it is ok and 503 otherwise.
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if report.Status != checkOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// validateRuntimeConfig checks the settings the proxy needs to forward
+// requests at all.
+func validateRuntimeConfig(cfg config.Config) error {
+	switch {
+	case cfg.Upstream == nil || !cfg.Upstream.IsAbs():
+		return errors.New("upstream URL must be absolute")
+	case cfg.AuthMode == auth.ModeBearer && cfg.BearerToken == "":
+		return errors.New("bearer token is not configured")
+	case cfg.AuthMode != auth.ModeBearer && (cfg.APIKey == "" || cfg.APISecret == ""):
+		return errors.New("API key and secret are not configured")
+	case cfg.AuthMode == auth.ModeOAuth2 && cfg.OAuthTokenURL == "":
+		return errors.New("OAuth2 token URL is not configured")
+	}
+	return nil
+}
+
+// RunReadinessProbe probes the upstream every cfg.ReadyProbeInterval until
+// ctx is done, caching the outcome for /readyz. It returns immediately when
+// probing is disabled.
+func (p *Proxy) RunReadinessProbe(ctx context.Context) {
+	if p.cfg.ReadyProbeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.cfg.ReadyProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		result := p.probeUpstream(ctx)
+		if result.Status != checkOK {
+			p.logger.Warn().
+				Str("error", result.Error).
+				Int("status", result.HTTPStatus).
+				Msg("upstream readiness probe failed")
+		}
+		p.readiness.set(result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeUpstream sends one signed probe: a GET to cfg.ReadyProbePath when set,
+// otherwise an MCP ping posted to the default MCP path. Any answer other than
+// 401, 403 or a 5xx counts as reachable.
+func (p *Proxy) probeUpstream(ctx context.Context) checkResult {
+	method, path := http.MethodPost, defaultProbePath
+	var body []byte
+	if p.cfg.ReadyProbePath != "" {
+		method, path = http.MethodGet, p.cfg.ReadyProbePath
+	} else {
+		body = []byte(`{"jsonrpc":"2.0","id":"readiness-probe","method":"ping"}`)
+	}
+
+	start := time.Now()
+	result := checkResult{Status: checkFailed, CheckedAt: &start}
+
+	ctx = context.WithValue(ctx, readinessProbeKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+	}
+
+	upstreamReq, err := p.newUpstreamRequest(req, body)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := p.doWithDeadline(upstreamReq)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if err := resp.Body.Close(); err != nil {
+		p.logger.Error().Err(err).Msg("close upstream response body failed")
+	}
+
+	result.HTTPStatus = resp.StatusCode
+	result.LatencyMS = time.Since(start).Milliseconds()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		result.Error = fmt.Sprintf("upstream rejected credentials: %s", resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		result.Error = fmt.Sprintf("upstream unavailable: %s", resp.Status)
+	default:
+		result.Status = checkOK
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+)
+
+func decodeHealthReport(t *testing.T, rec *httptest.ResponseRecorder) healthReport {
+	t.Helper()
+
+	var report healthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode health report %q: %v", rec.Body.String(), err)
+	}
+	return report
+}
+
+func TestHealthEndpointsAreAnsweredLocally(t *testing.T) {
+	var forwarded atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.InboundToken = "client-token"
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy"+PathHealthz, nil))
+	if rec.Code != http.StatusOK || decodeHealthReport(t, rec).Status != checkOK {
+		t.Fatalf("unexpected liveness answer %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Probing is disabled, so readiness only depends on the configuration.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy"+PathReadyz, nil))
+	report := decodeHealthReport(t, rec)
+	if rec.Code != http.StatusOK || report.Checks["config"].Status != checkOK {
+		t.Fatalf("unexpected readiness answer %d %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := report.Checks["upstream"]; ok {
+		t.Fatal("upstream check reported while probing is disabled")
+	}
+
+	if forwarded.Load() != 0 {
+		t.Fatal("health probes must not reach the upstream")
+	}
+}
+
+func TestReadinessReflectsUpstreamProbe(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	type probe struct {
+		method, path, signature, body string
+	}
+	probes := make(chan probe, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		probes <- probe{r.Method, r.URL.Path, r.Header.Get(auth.HeaderSignature), string(body)}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.ReadyProbeInterval = time.Hour
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	readyz := func() (int, healthReport) {
+		rec := httptest.NewRecorder()
+		p.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathReadyz, nil))
+		return rec.Code, decodeHealthReport(t, rec)
+	}
+
+	if code, report := readyz(); code != http.StatusServiceUnavailable || report.Checks["upstream"].Status != checkPending {
+		t.Fatalf("expected pending readiness before the first probe, got %d %+v", code, report)
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{name: "reachable", status: http.StatusOK, want: http.StatusOK},
+		{name: "session required", status: http.StatusBadRequest, want: http.StatusOK},
+		{name: "credentials rejected", status: http.StatusUnauthorized, want: http.StatusServiceUnavailable},
+		{name: "unavailable", status: http.StatusBadGateway, want: http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status.Store(int32(tc.status))
+			p.readiness.set(p.probeUpstream(context.Background()))
+
+			got := <-probes
+			if got.method != http.MethodPost || got.path != defaultProbePath {
+				t.Fatalf("unexpected probe %s %s", got.method, got.path)
+			}
+			if got.signature == "" {
+				t.Fatal("probe was not signed")
+			}
+			if got.body != `{"jsonrpc":"2.0","id":"readiness-probe","method":"ping"}` {
+				t.Fatalf("unexpected probe body %s", got.body)
+			}
+
+			code, report := readyz()
+			upstreamCheck := report.Checks["upstream"]
+			if code != tc.want || upstreamCheck.HTTPStatus != tc.status || upstreamCheck.CheckedAt == nil {
+				t.Fatalf("unexpected readiness %d %+v", code, report)
+			}
+		})
+	}
+}
+
+func TestReadinessProbeUsesConfiguredPath(t *testing.T) {
+	probed := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probed <- r.Method + " " + r.URL.Path
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.ReadyProbeInterval = time.Hour
+	cfg.ReadyProbePath = "/status"
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.RunReadinessProbe(ctx)
+		close(done)
+	}()
+
+	if got := <-probed; got != "GET /status" {
+		t.Fatalf("unexpected probe %s", got)
+	}
+	waitUntil(t, time.Second, func() bool { return p.readiness.get() != nil })
+	cancel()
+	<-done
+
+	if result := p.readiness.get(); result.Status != checkOK {
+		t.Fatalf("unexpected probe result %+v", result)
+	}
+}
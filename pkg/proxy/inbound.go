@@ -0,0 +1,221 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/authserver"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// clientIdentityKey stores the authenticated inbound identity in a request
+// context.
+type clientIdentityKey struct{}
+
+// clientIdentity returns the identity attached by inbound authentication, or
+// an empty string when the request was not authenticated.
+func clientIdentity(ctx context.Context) string {
+	id, _ := ctx.Value(clientIdentityKey{}).(string)
+	return id
+}
+
+// inboundAuth verifies local MCP clients before their requests are signed
+// with the team's upstream credentials. When several methods are configured a
+// request passes if any one of them succeeds.
+type inboundAuth struct {
+	// sharedToken is a single bearer token accepted from every client.
+	sharedToken string
+	// clientTokens maps the SHA-256 digest of each per-client token to the
+	// client name declared in the tokens file.
+	clientTokens map[string]string
+	// requireClientCert accepts clients whose TLS certificate chain was
+	// verified against the configured client CA.
+	requireClientCert bool
+	// authServer validates tokens issued by the local authorization server.
+	authServer *authserver.Server
+}
+
+// newInboundAuth assembles the inbound verifier from configuration. It returns
+// nil when no inbound authentication is configured.
+func newInboundAuth(cfg config.Config, authServer *authserver.Server) (*inboundAuth, error) {
+	a := &inboundAuth{
+		sharedToken:       cfg.InboundToken,
+		requireClientCert: cfg.TLSClientCAFile != "",
+		authServer:        authServer,
+	}
+
+	if cfg.InboundTokensFile != "" {
+		tokens, err := loadClientTokens(cfg.InboundTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		a.clientTokens = tokens
+	}
+
+	if a.sharedToken == "" && len(a.clientTokens) == 0 && !a.requireClientCert && a.authServer == nil {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// authenticate returns the client identity, or false when no configured
+// method accepts the request. Local bearer tokens are stripped so they never
+// reach the upstream.
+func (a *inboundAuth) authenticate(r *http.Request) (string, bool) {
+	identity, ok := a.credential(r)
+	if !ok && a.authServer != nil {
+		token, _ := bearerToken(r.Header.Get("Authorization"))
+		var clientID string
+		if clientID, ok = a.authServer.ValidateToken(token); ok {
+			identity = "oauth:" + clientID
+		}
+	}
+	if !ok {
+		return "", false
+	}
+
+	r.Header.Del("Authorization")
+	return identity, true
+}
+
+// hasCredential reports whether a method other than the authorization server
+// is configured.
+func (a *inboundAuth) hasCredential() bool {
+	return a.sharedToken != "" || len(a.clientTokens) > 0 || a.requireClientCert
+}
+
+// credential checks the proxy's own inbound credentials: a verified client
+// certificate, the shared token or a per-client token. Tokens issued by the
+// authorization server are not considered, so they cannot be used to obtain
+// more of them.
+func (a *inboundAuth) credential(r *http.Request) (string, bool) {
+	if a.requireClientCert && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+	}
+
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return "", false
+	}
+	if a.sharedToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.sharedToken)) == 1 {
+		return "shared", true
+	}
+	if clientName := a.clientTokens[digestToken(token)]; clientName != "" {
+		return clientName, true
+	}
+	return "", false
+}
+
+// approveAuthServer gates registration and authorization at the local
+// authorization server. When other inbound methods are configured the caller
+// must pass one of them, otherwise anyone could mint a token and bypass them.
+// Without any, config.Load only allows the server on a loopback listener.
+func (a *inboundAuth) approveAuthServer(r *http.Request) bool {
+	if a == nil || !a.hasCredential() {
+		return true
+	}
+	_, ok := a.credential(r)
+	return ok
+}
+
+// challenge writes the 401 response for a rejected request.
+func (a *inboundAuth) challenge(w http.ResponseWriter, r *http.Request) {
+	challenge := `Bearer realm="mcp-auth-proxy"`
+	if a.authServer != nil {
+		challenge = fmt.Sprintf(`Bearer resource_metadata=%q`, a.authServer.ResourceMetadataURL(r))
+	}
+	if _, ok := bearerToken(r.Header.Get("Authorization")); ok {
+		challenge += `, error="invalid_token"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeLocalError(w, r, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// loadClientTokens parses a tokens file with one "<client> <token>" pair per
+// line. Blank lines and lines starting with '#' are ignored.
+func loadClientTokens(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open inbound tokens file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("inbound tokens file %s:%d: expected \"<client> <token>\"", path, lineNo)
+		}
+		key := digestToken(fields[1])
+		if _, dup := tokens[key]; dup {
+			return nil, fmt.Errorf("inbound tokens file %s:%d: duplicate token", path, lineNo)
+		}
+		tokens[key] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read inbound tokens file: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("inbound tokens file contains no tokens")
+	}
+	return tokens, nil
+}
+
+// bearerToken extracts the credential from an "Authorization: Bearer" value.
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// digestToken hashes a token so lookups do not compare raw secrets.
+func digestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ServerTLSConfig returns the listener TLS configuration, or nil when the
+// proxy should serve plain HTTP. With a client CA, certificates are verified
+// during the handshake when presented; requests without one are rejected
+// with 401 by the handler.
+func ServerTLSConfig(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client CA file contains no PEM certificates")
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, nil
+}
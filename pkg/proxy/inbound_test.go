@@ -0,0 +1,179 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+func TestProxyInboundAuthentication(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	contents := "# client tokens\nalice alice-token\n\nbob bob-token\n"
+	if err := os.WriteFile(tokensFile, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:          upstreamURL,
+		APIKey:            "key-id",
+		APISecret:         "secret-value",
+		RequestTimeout:    time.Second,
+		InboundToken:      "shared-token",
+		InboundTokensFile: tokensFile,
+		TLSClientCAFile:   "ca.pem",
+	}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p, ok := handler.(*Proxy)
+	if !ok {
+		t.Fatalf("expected *Proxy, got %T", handler)
+	}
+
+	var (
+		outboundCalls int32
+		lastAuth      atomic.Value
+	)
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&outboundCalls, 1)
+		lastAuth.Store(req.Header.Get("Authorization"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	verifiedTLS := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "agent-1"}}}},
+	}
+	unverifiedTLS := &tls.ConnectionState{}
+
+	tests := []struct {
+		name       string
+		auth       string
+		tls        *tls.ConnectionState
+		wantStatus int
+		wantError  bool
+	}{
+		{name: "no credentials", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer nope", wantStatus: http.StatusUnauthorized, wantError: true},
+		{name: "basic scheme", auth: "Basic c2hhcmVkLXRva2Vu", wantStatus: http.StatusUnauthorized},
+		{name: "shared token", auth: "Bearer shared-token", wantStatus: http.StatusOK},
+		{name: "per-client token", auth: "Bearer bob-token", wantStatus: http.StatusOK},
+		{name: "unverified certificate", tls: unverifiedTLS, wantStatus: http.StatusUnauthorized},
+		{name: "verified certificate", tls: verifiedTLS, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&outboundCalls, 0)
+
+			req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader("{}"))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			calls := atomic.LoadInt32(&outboundCalls)
+			if tt.wantStatus == http.StatusUnauthorized {
+				if calls != 0 {
+					t.Fatal("rejected request reached upstream")
+				}
+				challenge := rec.Header().Get("WWW-Authenticate")
+				if !strings.HasPrefix(challenge, "Bearer ") {
+					t.Fatalf("missing bearer challenge: %q", challenge)
+				}
+				if got := strings.Contains(challenge, `error="invalid_token"`); got != tt.wantError {
+					t.Fatalf("unexpected challenge %q", challenge)
+				}
+				return
+			}
+			if calls != 1 {
+				t.Fatalf("expected one upstream call, got %d", calls)
+			}
+			if got := lastAuth.Load(); got != "" {
+				t.Fatalf("inbound token leaked upstream: %q", got)
+			}
+		})
+	}
+}
+
+func TestAuthServerRequiresInboundCredential(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+	handler, err := New(config.Config{
+		Upstream:          upstreamURL,
+		APIKey:            "key-id",
+		APISecret:         "secret-value",
+		RequestTimeout:    time.Second,
+		InboundToken:      "shared-token",
+		AuthServerEnabled: true,
+	})
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "shared token", auth: "Bearer shared-token", wantStatus: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://proxy/register", strings.NewReader(`{"redirect_uris":["http://localhost:9000/cb"]}`))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("register answered %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoadClientTokensRejectsMalformedLines(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("alice\n"), 0o600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+	if _, err := loadClientTokens(tokensFile); err == nil {
+		t.Fatal("expected error for malformed line")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// maxInspectBody bounds how much of a JSON response is retained for logging.
+const maxInspectBody = 64 * 1024
+
+// rpcMessage holds the fields of a JSON-RPC message that are worth logging.
+// It is decoded from a copy of the payload; the forwarded bytes never change.
+type rpcMessage struct {
+	rpcEnvelope
+	Params json.RawMessage `json:"params,omitempty"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+// rpcCallParams picks the target of tools/call and resources/read requests
+// and the arguments of tool calls.
+type rpcCallParams struct {
+	Name      string          `json:"name"`
+	URI       string          `json:"uri"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// decodeRPC parses a single JSON-RPC message or a batch. Malformed payloads
+// and batch entries are skipped rather than reported; the upstream is the
+// authority on validity.
+func decodeRPC(payload []byte) (msgs []rpcMessage, batch bool) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil, false
+	}
+
+	if payload[0] != '[' {
+		var msg rpcMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return nil, false
+		}
+		return []rpcMessage{msg}, false
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, true
+	}
+	for _, item := range raw {
+		var msg rpcMessage
+		if err := json.Unmarshal(item, &msg); err == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, true
+}
+
+// target returns the tool name or resource URI a request operates on.
+func (m *rpcMessage) target() (tool, uri string) {
+	if m.Method != "tools/call" && m.Method != "resources/read" {
+		return "", ""
+	}
+	var params rpcCallParams
+	if err := json.Unmarshal(m.Params, &params); err != nil {
+		return "", ""
+	}
+	if m.Method == "tools/call" {
+		return params.Name, ""
+	}
+	return "", params.URI
+}
+
+// withRPCRequest adds the JSON-RPC method, id and call target of payload to
+// the log context. Batches are summarised with one entry per message.
+func withRPCRequest(ctx zerolog.Context, payload []byte) zerolog.Context {
+	msgs, batch := decodeRPC(payload)
+	if !batch {
+		if len(msgs) == 0 {
+			return ctx
+		}
+		msg := msgs[0]
+		if msg.Method != "" {
+			ctx = ctx.Str("rpc_method", msg.Method)
+		}
+		if len(msg.ID) > 0 {
+			ctx = ctx.RawJSON("rpc_id", msg.ID)
+		}
+		tool, uri := msg.target()
+		if tool != "" {
+			ctx = ctx.Str("tool", tool)
+		}
+		if uri != "" {
+			ctx = ctx.Str("resource_uri", uri)
+		}
+		return ctx
+	}
+
+	var methods, ids, tools, uris []string
+	for i := range msgs {
+		methods = append(methods, msgs[i].Method)
+		if len(msgs[i].ID) > 0 {
+			ids = append(ids, string(msgs[i].ID))
+		}
+		tool, uri := msgs[i].target()
+		if tool != "" {
+			tools = append(tools, tool)
+		}
+		if uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	ctx = ctx.Int("rpc_batch", len(msgs)).Strs("rpc_methods", methods)
+	if len(ids) > 0 {
+		ctx = ctx.Strs("rpc_ids", ids)
+	}
+	if len(tools) > 0 {
+		ctx = ctx.Strs("tools", tools)
+	}
+	if len(uris) > 0 {
+		ctx = ctx.Strs("resource_uris", uris)
+	}
+	return ctx
+}
+
+// rpcErrorCodes collects the JSON-RPC error codes carried by payload.
+func rpcErrorCodes(payload []byte) []int {
+	msgs, _ := decodeRPC(payload)
+	var codes []int
+	for _, msg := range msgs {
+		if msg.Error != nil {
+			codes = append(codes, msg.Error.Code)
+		}
+	}
+	return codes
+}
+
+// logRPCErrors attaches JSON-RPC error codes to a log event.
+func logRPCErrors(e *zerolog.Event, codes []int) *zerolog.Event {
+	switch len(codes) {
+	case 0:
+		return e
+	case 1:
+		return e.Int("rpc_error_code", codes[0])
+	default:
+		return e.Ints("rpc_error_codes", codes)
+	}
+}
+
+// warnRPCErrors logs a warning when payload carries JSON-RPC errors.
+func warnRPCErrors(event zerolog.Logger, payload []byte) {
+	if codes := rpcErrorCodes(payload); len(codes) > 0 {
+		logRPCErrors(event.Warn(), codes).Msg("upstream returned JSON-RPC error")
+	}
+}
+
+// bufferRequestBody reads a POST body so it can be inspected and puts an
+// identical copy back on r for forwarding. Other methods yield no payload.
+func bufferRequestBody(r *http.Request, event zerolog.Logger) ([]byte, error) {
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if closeErr := r.Body.Close(); closeErr != nil {
+		event.Error().Err(closeErr).Msg("close request body failed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	return payload, nil
+}
+
+// isJSONResponse reports whether the upstream answered with JSON.
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// captureBuffer keeps the first limit bytes written to it and remembers
+// whether anything was dropped.
+type captureBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer; it never fails so it is safe in an io.TeeReader.
+func (c *captureBuffer) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	c.buf.Write(p)
+	return len(p), nil
+}
+
+// errorCodes returns the JSON-RPC error codes of a fully captured payload.
+func (c *captureBuffer) errorCodes() []int {
+	if c.truncated {
+		return nil
+	}
+	return rpcErrorCodes(c.buf.Bytes())
+}
@@ -0,0 +1,96 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestProxyLogsJSONRPCFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		response string
+		want     map[string]any
+	}{
+		{
+			name:     "tool call",
+			request:  `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search","arguments":{"q":"x"}}}`,
+			response: `{"jsonrpc":"2.0","id":7,"error":{"code":-32602,"message":"bad args"}}`,
+			want: map[string]any{
+				"rpc_method":     "tools/call",
+				"rpc_id":         float64(7),
+				"tool":           "search",
+				"rpc_error_code": float64(-32602),
+			},
+		},
+		{
+			name: "batch",
+			request: ` [ {"jsonrpc":"2.0","id":"a","method":"resources/read","params":{"uri":"file:///x"}},
+			            {"jsonrpc":"2.0","method":"notifications/initialized"} ] `,
+			response: `[{"jsonrpc":"2.0","id":"a","result":{}}]`,
+			want: map[string]any{
+				"rpc_batch":     float64(2),
+				"rpc_methods":   []any{"resources/read", "notifications/initialized"},
+				"rpc_ids":       []any{`"a"`},
+				"resource_uris": []any{"file:///x"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var forwarded []byte
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded, _ = io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, tc.response)
+			}))
+			defer upstream.Close()
+
+			handler, err := New(newStreamTestConfig(t, upstream.URL))
+			if err != nil {
+				t.Fatalf("create proxy: %v", err)
+			}
+			var logs bytes.Buffer
+			p := handler.(*Proxy)
+			p.logger = zerolog.New(&logs)
+
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(tc.request)))
+
+			if rec.Body.String() != tc.response {
+				t.Fatalf("unexpected response body: %s", rec.Body.String())
+			}
+			if string(forwarded) != tc.request {
+				t.Fatalf("forwarded body was altered: %q", forwarded)
+			}
+
+			var entry map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("decode log line %q: %v", line, err)
+				}
+				if entry["message"] == "request proxied" {
+					break
+				}
+			}
+			for key, want := range tc.want {
+				got, _ := json.Marshal(entry[key])
+				expected, _ := json.Marshal(want)
+				if !bytes.Equal(got, expected) {
+					t.Errorf("log field %s = %s, want %s", key, got, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+// PathMetrics is where the Prometheus metrics are served, on the admin
+// listener when one is configured and on the main listener otherwise.
+const PathMetrics = "/metrics"
+
+// AdminHandler serves the operational endpoints for a dedicated admin
+// listener configured with MCP_ADMIN_ADDR.
+func (p *Proxy) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+PathMetrics, p.metrics.Handler())
+	mux.HandleFunc("GET "+PathHealthz, p.serveHealth)
+	mux.HandleFunc("GET "+PathReadyz, p.serveHealth)
+	return mux
+}
+
+// knownRPCMethods bounds the rpc_method metric label to the MCP methods; any
+// other value is reported as "other" so clients cannot inflate cardinality.
+var knownRPCMethods = map[string]struct{}{
+	"initialize":                       {},
+	"ping":                             {},
+	"tools/list":                       {},
+	"tools/call":                       {},
+	"resources/list":                   {},
+	"resources/read":                   {},
+	"resources/templates/list":         {},
+	"resources/subscribe":              {},
+	"resources/unsubscribe":            {},
+	"prompts/list":                     {},
+	"prompts/get":                      {},
+	"completion/complete":              {},
+	"logging/setLevel":                 {},
+	"notifications/initialized":        {},
+	"notifications/cancelled":          {},
+	"notifications/progress":           {},
+	"notifications/roots/list_changed": {},
+}
+
+// rpcMethodLabel derives the rpc_method metric label from a request payload.
+func rpcMethodLabel(payload []byte) string {
+	msgs, batch := decodeRPC(payload)
+	switch {
+	case batch:
+		return "batch"
+	case len(msgs) == 0:
+		return "none"
+	case msgs[0].Method == "":
+		// Clients answer server-initiated requests with method-less messages.
+		return "response"
+	}
+	if _, ok := knownRPCMethods[msgs[0].Method]; ok {
+		return msgs[0].Method
+	}
+	return "other"
+}
+
+// requestStats collects per-request facts that are only known while the
+// request is being served.
+type requestStats struct {
+	// rpcMethod is the rpc_method metric label.
+	rpcMethod string
+}
+
+// metricsWriter records the status and body size of a response while
+// preserving flushing for event streams.
+type metricsWriter struct {
+	http.ResponseWriter
+	metrics *metrics.Metrics
+	status  int
+}
+
+// WriteHeader records the status before forwarding it.
+func (w *metricsWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes sent to the client.
+func (w *metricsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.metrics.AddSentBytes(n)
+	return n, err
+}
+
+// Flush forwards to the underlying writer when it supports flushing.
+func (w *metricsWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *metricsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the recorded status, defaulting to 200 like net/http.
+func (w *metricsWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// countingBody counts request body bytes as they are read.
+type countingBody struct {
+	io.ReadCloser
+	metrics *metrics.Metrics
+}
+
+// Read implements io.Reader.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.metrics.AddReceivedBytes(n)
+	return n, err
+}
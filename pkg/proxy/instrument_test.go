@@ -0,0 +1,80 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProxyExposesMetrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "broken") {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	for _, path := range []string{"/mcp", "/broken"} {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy"+path,
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"x-custom/thing"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy"+PathMetrics, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected metrics status: %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`mcp_proxy_requests_total{rpc_method="tools/list",status="200"} 1`,
+		`mcp_proxy_requests_total{rpc_method="tools/list",status="503"} 1`,
+		`mcp_proxy_requests_total{rpc_method="other",status="200"} 1`,
+		`mcp_proxy_request_duration_seconds_count{rpc_method="tools/list",status="200"} 1`,
+		`mcp_proxy_upstream_errors_total{class="5xx"} 1`,
+		`mcp_proxy_upstream_errors_total{class="timeout"} 0`,
+		`mcp_proxy_active_sse_streams{source="local"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+	for _, counter := range []string{"mcp_proxy_received_bytes_total ", "mcp_proxy_sent_bytes_total "} {
+		if !strings.Contains(body, counter) || strings.Contains(body, counter+"0\n") {
+			t.Errorf("expected %s to count traffic", strings.TrimSpace(counter))
+		}
+	}
+}
+
+func TestAdminHandlerServesMetricsSeparately(t *testing.T) {
+	cfg := newStreamTestConfig(t, "http://upstream.invalid")
+	cfg.AdminAddr = "127.0.0.1:0"
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	rec := httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathMetrics, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "mcp_proxy_upstream_errors_total") {
+		t.Fatalf("admin listener did not serve metrics: %d", rec.Code)
+	}
+}
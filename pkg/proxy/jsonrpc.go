@@ -0,0 +1,205 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSON-RPC error codes used for locally generated responses.
+const (
+	rpcCodeInvalidRequest = -32600
+	rpcCodeMethodNotFound = -32601
+	rpcCodeInvalidParams  = -32602
+	rpcCodeInternalError  = -32603
+)
+
+// rpcEnvelope captures the routing fields of a JSON-RPC message without
+// decoding its params or result.
+type rpcEnvelope struct {
+	// ID is the raw request id; it is absent for notifications.
+	ID json.RawMessage `json:"id,omitempty"`
+	// Method is set on requests and notifications.
+	Method string `json:"method,omitempty"`
+}
+
+// isRequest reports whether the message expects a response.
+func (e *rpcEnvelope) isRequest() bool {
+	return e.Method != "" && len(e.ID) > 0 && string(e.ID) != "null"
+}
+
+// rpcErrorObject mirrors the JSON-RPC 2.0 error member.
+type rpcErrorObject struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// rpcErrorResponse is a JSON-RPC 2.0 response carrying an error.
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcErrorObject  `json:"error"`
+}
+
+// newRPCError builds the encoded error response for the given JSON-RPC id.
+// A non-empty requestID is reported in the error data for correlation.
+func newRPCError(id json.RawMessage, code int, message, requestID string) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	errObj := rpcErrorObject{Code: code, Message: message}
+	if requestID != "" {
+		errObj.Data = map[string]string{"request_id": requestID}
+	}
+	payload, err := json.Marshal(rpcErrorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   errObj,
+	})
+	if err != nil {
+		// The inputs are always serialisable; fall back to a static payload.
+		return []byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"internal error"}}`)
+	}
+	return payload
+}
+
+// newRPCResult builds the encoded success response for the given JSON-RPC id.
+func newRPCResult(id json.RawMessage, result any) []byte {
+	payload, err := json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{JSONRPC: "2.0", ID: id, Result: result})
+	if err != nil {
+		return newRPCError(id, rpcCodeInternalError, "encode result failed", "")
+	}
+	return payload
+}
+
+// rpcErrorReplies answers every request in payload with the same error. It
+// returns a single response or a batch mirroring payload, and nil when
+// payload holds no request expecting an answer.
+func rpcErrorReplies(payload []byte, code int, message, requestID string) []byte {
+	msgs, batch := decodeRPC(payload)
+	var replies []json.RawMessage
+	for i := range msgs {
+		if msgs[i].isRequest() {
+			replies = append(replies, newRPCError(msgs[i].ID, code, message, requestID))
+		}
+	}
+	switch {
+	case len(replies) == 0:
+		return nil
+	case !batch:
+		return replies[0]
+	}
+	encoded, err := json.Marshal(replies)
+	if err != nil {
+		// Each reply is already valid JSON; marshalling cannot fail.
+		return nil
+	}
+	return encoded
+}
+
+// strictMessageKeys and strictParamKeys are the members the proxy reads to
+// route, limit and police a message. encoding/json matches keys without
+// regard to case and keeps the last duplicate, while upstreams may read the
+// first exact match, so a payload repeating one of them could show the proxy
+// a different method or tool than the upstream runs.
+var (
+	strictMessageKeys = []string{"jsonrpc", "id", "method", "params"}
+	strictParamKeys   = []string{"name", "arguments", "uri"}
+)
+
+// checkRPCKeys returns an error when a message in payload, or the params of
+// one, repeats a member listed above or spells it in another case, or when
+// tool call arguments repeat a key. Payloads that are not JSON objects are
+// left for the upstream to reject.
+func checkRPCKeys(payload []byte) error {
+	msgs := []json.RawMessage{payload}
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return nil
+		}
+		msgs = batch
+	}
+	for _, msg := range msgs {
+		members, ok := objectMembers(msg)
+		if !ok {
+			continue
+		}
+		if err := checkStrictKeys(members, strictMessageKeys); err != nil {
+			return err
+		}
+		params, ok := objectMembers(members.values["params"])
+		if !ok {
+			continue
+		}
+		if err := checkStrictKeys(params, strictParamKeys); err != nil {
+			return fmt.Errorf("params %w", err)
+		}
+		if args, ok := objectMembers(params.values["arguments"]); ok {
+			for key, count := range args.counts {
+				if count > 1 {
+					return fmt.Errorf("arguments member %q is repeated", key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rpcMembers holds the values of a JSON object by exact key, with the number
+// of times each key appeared.
+type rpcMembers struct {
+	values map[string]json.RawMessage
+	counts map[string]int
+}
+
+// objectMembers decodes raw as a JSON object keeping every key exactly as
+// written. It returns false when raw is not a well-formed object.
+func objectMembers(raw json.RawMessage) (rpcMembers, bool) {
+	members := rpcMembers{values: map[string]json.RawMessage{}, counts: map[string]int{}}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return members, false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return members, false
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return members, false
+		}
+		members.counts[key]++
+		if _, seen := members.values[key]; !seen {
+			members.values[key] = value
+		}
+	}
+	return members, true
+}
+
+// checkStrictKeys reports a key of members that repeats one of names or
+// matches it only without regard to case.
+func checkStrictKeys(members rpcMembers, names []string) error {
+	for key, count := range members.counts {
+		for _, name := range names {
+			switch {
+			case key == name && count > 1:
+				return fmt.Errorf("member %q is repeated", key)
+			case key != name && strings.EqualFold(key, name):
+				return fmt.Errorf("member %q is ambiguous with %q", key, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/policy"
+)
+
+// toolListKey stores the ids of tools/list requests in a request context so
+// their responses can be filtered against the tool policy.
+type toolListKey struct{}
+
+// toolListFilter returns the tools/list request ids recorded on ctx.
+func toolListFilter(ctx context.Context) map[string]struct{} {
+	ids, _ := ctx.Value(toolListKey{}).(map[string]struct{})
+	return ids
+}
+
+// checkAmbiguousRPC refuses payloads that checkRPCKeys rejects, before the
+// tool policy, rate limiter or aggregator decode them. It returns the
+// JSON-RPC error to send instead of forwarding, and true. The error answers
+// every request in payload, or carries a null id when none was recognised,
+// so a refused notification is not mistaken for an accepted one.
+func checkAmbiguousRPC(payload []byte, requestID string, event zerolog.Logger) ([]byte, bool) {
+	err := checkRPCKeys(payload)
+	if err == nil {
+		return nil, false
+	}
+	event.Warn().Err(err).Msg("ambiguous JSON-RPC payload rejected")
+	message := fmt.Sprintf("invalid request: %v", err)
+	if reply := rpcErrorReplies(payload, rpcCodeInvalidRequest, message, requestID); reply != nil {
+		return reply, true
+	}
+	return newRPCError(nil, rpcCodeInvalidRequest, message, requestID), true
+}
+
+// checkToolPolicy evaluates every tools/call request in payload. When one is
+// refused it returns the JSON-RPC response to send instead of forwarding, and
+// true. A refused call inside a batch fails the whole batch so no request is
+// left without an answer.
+func (p *Proxy) checkToolPolicy(payload []byte, requestID string, event zerolog.Logger) ([]byte, bool) {
+	toolPolicy := p.policy.Load()
+	if toolPolicy == nil {
+		return nil, false
+	}
+
+	msgs, batch := decodeRPC(payload)
+	reasons := make([]string, len(msgs))
+	denied := false
+	for i := range msgs {
+		if msgs[i].Method != "tools/call" {
+			continue
+		}
+		var params rpcCallParams
+		if err := json.Unmarshal(msgs[i].Params, &params); err != nil {
+			reasons[i] = "tools/call params must be an object"
+		} else if err := toolPolicy.CheckCall(params.Name, params.Arguments); err != nil {
+			reasons[i] = fmt.Sprintf("%v by proxy policy", err)
+		}
+		if reasons[i] != "" {
+			denied = true
+			event.Warn().
+				Str("tool", params.Name).
+				Str("reason", reasons[i]).
+				Msg("tool call denied by policy")
+		}
+	}
+	if !denied {
+		return nil, false
+	}
+
+	var replies []json.RawMessage
+	for i := range msgs {
+		if !msgs[i].isRequest() {
+			continue
+		}
+		if reasons[i] != "" {
+			replies = append(replies, newRPCError(msgs[i].ID, rpcCodeInvalidParams, reasons[i], requestID))
+		} else {
+			replies = append(replies, newRPCError(msgs[i].ID, rpcCodeInvalidRequest, "batch rejected: it contains a tool call denied by proxy policy", requestID))
+		}
+	}
+
+	switch {
+	case len(replies) == 0:
+		return nil, true
+	case !batch:
+		return replies[0], true
+	}
+	encoded, err := json.Marshal(replies)
+	if err != nil {
+		// Each reply is already valid JSON; marshalling cannot fail.
+		return nil, true
+	}
+	return encoded, true
+}
+
+// writeLocalRPC answers the client with a locally generated JSON-RPC payload.
+// An empty payload is acknowledged like a notification.
+func writeLocalRPC(w http.ResponseWriter, payload []byte) {
+	if len(payload) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(payload)
+}
+
+// toolListIDs returns the ids of the tools/list requests in payload.
+func toolListIDs(payload []byte) map[string]struct{} {
+	msgs, _ := decodeRPC(payload)
+	var ids map[string]struct{}
+	for i := range msgs {
+		if msgs[i].Method != "tools/list" || !msgs[i].isRequest() {
+			continue
+		}
+		if ids == nil {
+			ids = make(map[string]struct{})
+		}
+		ids[string(msgs[i].ID)] = struct{}{}
+	}
+	return ids
+}
+
+// filterToolList removes tools the policy refuses from the tools/list results
+// in payload whose ids are listed. It returns the rewritten payload and true,
+// or the original payload and false when nothing was removed.
+func (p *Proxy) filterToolList(payload []byte, ids map[string]struct{}) ([]byte, bool) {
+	toolPolicy := p.policy.Load()
+	if toolPolicy == nil || len(ids) == 0 {
+		return payload, false
+	}
+
+	var (
+		batch []json.RawMessage
+		msgs  []json.RawMessage
+	)
+	if err := json.Unmarshal(payload, &batch); err == nil {
+		msgs = batch
+	} else {
+		msgs = []json.RawMessage{payload}
+	}
+
+	changed := false
+	for i, raw := range msgs {
+		if filtered, ok := filterToolListMessage(toolPolicy, raw, ids); ok {
+			msgs[i] = filtered
+			changed = true
+		}
+	}
+	if !changed {
+		return payload, false
+	}
+
+	if batch == nil {
+		return msgs[0], true
+	}
+	encoded, err := json.Marshal(msgs)
+	if err != nil {
+		return payload, false
+	}
+	return encoded, true
+}
+
+// filterJSONToolList buffers a JSON tools/list response and returns a reader
+// over the filtered body, dropping Content-Length when the body changed.
+func (p *Proxy) filterJSONToolList(resp *http.Response, ids map[string]struct{}, event zerolog.Logger) io.Reader {
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		event.Error().Err(err).Msg("read upstream tools/list response failed")
+		return bytes.NewReader(payload)
+	}
+	if filtered, ok := p.filterToolList(payload, ids); ok {
+		resp.Header.Del("Content-Length")
+		event.Debug().Msg("filtered tools/list response by policy")
+		return bytes.NewReader(filtered)
+	}
+	return bytes.NewReader(payload)
+}
+
+// filterToolListMessage filters a single JSON-RPC response by toolPolicy.
+func filterToolListMessage(toolPolicy *policy.Policy, raw json.RawMessage, ids map[string]struct{}) (json.RawMessage, bool) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return raw, false
+	}
+	if _, ok := ids[string(msg["id"])]; !ok || msg["result"] == nil {
+		return raw, false
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(msg["result"], &result); err != nil {
+		return raw, false
+	}
+	var tools []json.RawMessage
+	if err := json.Unmarshal(result["tools"], &tools); err != nil {
+		return raw, false
+	}
+
+	kept := make([]json.RawMessage, 0, len(tools))
+	for _, tool := range tools {
+		var desc struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(tool, &desc); err == nil && !toolPolicy.ToolAllowed(desc.Name) {
+			continue
+		}
+		kept = append(kept, tool)
+	}
+	if len(kept) == len(tools) {
+		return raw, false
+	}
+
+	var err error
+	if result["tools"], err = json.Marshal(kept); err != nil {
+		return raw, false
+	}
+	if msg["result"], err = json.Marshal(result); err != nil {
+		return raw, false
+	}
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return raw, false
+	}
+	return encoded, true
+}
@@ -0,0 +1,223 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testToolList = `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"search"},{"name":"fs_read"},{"name":"fs_delete"}]}}`
+
+func newPolicyTestProxy(t *testing.T, upstream http.Handler) http.Handler {
+	t.Helper()
+
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	doc := `{"allow":["search","fs_*"],"deny":["fs_delete"],"arguments":{"fs_*":{"path":["/workspace/*"]}}}`
+	if err := os.WriteFile(policyFile, []byte(doc), 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+
+	cfg := newStreamTestConfig(t, server.URL)
+	cfg.ToolPolicyFile = policyFile
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	return handler
+}
+
+func TestProxyEnforcesToolPolicy(t *testing.T) {
+	var forwarded atomic.Int32
+	handler := newPolicyTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+
+	tests := []struct {
+		name      string
+		body      string
+		forwarded bool
+		code      int
+	}{
+		{name: "allowed call", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_read","arguments":{"path":"/workspace/a"}}}`, forwarded: true},
+		{name: "denied tool", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_delete","arguments":{}}}`, code: rpcCodeInvalidParams},
+		{name: "denied argument", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_read","arguments":{"path":"/etc/passwd"}}}`, code: rpcCodeInvalidParams},
+		{name: "unlisted tool", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"shell"}}`, code: rpcCodeInvalidParams},
+		{name: "other methods pass", body: `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`, forwarded: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := forwarded.Load()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(tc.body)))
+
+			if got := forwarded.Load() > before; got != tc.forwarded {
+				t.Fatalf("forwarded = %v, want %v", got, tc.forwarded)
+			}
+			if tc.forwarded {
+				return
+			}
+
+			var reply rpcErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("decode local reply %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != http.StatusOK || reply.Error.Code != tc.code || string(reply.ID) != "1" {
+				t.Fatalf("unexpected local reply %d %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyRejectsAmbiguousMembers(t *testing.T) {
+	var forwarded atomic.Int32
+	handler := newPolicyTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+
+	tests := []struct {
+		name string
+		body string
+		id   string
+	}{
+		{name: "name case variant", body: `{"method":"tools/call","params":{"name":"fs_delete","Name":"search"}}`, id: "null"},
+		{name: "method case variant", body: `{"method":"tools/call","Method":"ping","params":{"name":"fs_delete"}}`, id: "null"},
+		{name: "repeated name", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_delete","name":"search"}}`, id: "1"},
+		{name: "repeated argument", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_read","arguments":{"path":"/etc/passwd","path":"/workspace/a"}}}`, id: "1"},
+		{name: "params case variant", body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"},"PARAMS":{"name":"fs_delete"}}`, id: "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(tc.body)))
+
+			if forwarded.Load() != 0 {
+				t.Fatal("ambiguous payload must not reach the upstream")
+			}
+			var reply rpcErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("decode local reply %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != http.StatusOK || reply.Error.Code != rpcCodeInvalidRequest || string(reply.ID) != tc.id {
+				t.Fatalf("unexpected local reply %d %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyRejectsBatchesWithDeniedCalls(t *testing.T) {
+	var forwarded atomic.Int32
+	handler := newPolicyTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+	}))
+
+	body := `[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fs_delete"}},
+		{"jsonrpc":"2.0","method":"notifications/progress"}]`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(body)))
+
+	if forwarded.Load() != 0 {
+		t.Fatal("batch with a denied call must not reach the upstream")
+	}
+	var replies []rpcErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &replies); err != nil {
+		t.Fatalf("decode replies %q: %v", rec.Body.String(), err)
+	}
+	if len(replies) != 2 ||
+		replies[0].Error.Code != rpcCodeInvalidRequest ||
+		replies[1].Error.Code != rpcCodeInvalidParams {
+		t.Fatalf("unexpected replies: %s", rec.Body.String())
+	}
+}
+
+func TestProxyFiltersToolList(t *testing.T) {
+	for _, contentType := range []string{"application/json", "text/event-stream"} {
+		t.Run(contentType, func(t *testing.T) {
+			handler := newPolicyTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				if contentType == "text/event-stream" {
+					fmt.Fprintf(w, "data: %s\n\n", testToolList)
+					return
+				}
+				w.Header().Set("Content-Length", fmt.Sprint(len(testToolList)))
+				_, _ = io.WriteString(w, testToolList)
+			}))
+
+			rec := newFlushRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp",
+				strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)))
+
+			body := rec.String()
+			if contentType == "text/event-stream" {
+				body = strings.TrimSpace(strings.TrimPrefix(body, "data: "))
+			}
+			var list struct {
+				Result struct {
+					Tools []struct {
+						Name string `json:"name"`
+					} `json:"tools"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal([]byte(body), &list); err != nil {
+				t.Fatalf("decode tools/list %q: %v", body, err)
+			}
+			var names []string
+			for _, tool := range list.Result.Tools {
+				names = append(names, tool.Name)
+			}
+			if strings.Join(names, ",") != "search,fs_read" {
+				t.Fatalf("unexpected tools: %v", names)
+			}
+			if got := rec.Header().Get("Content-Length"); got != "" && got != fmt.Sprint(len(body)) {
+				t.Fatalf("stale content length %s for body of %d bytes", got, len(body))
+			}
+		})
+	}
+}
+
+func TestProxyFiltersCompressedToolList(t *testing.T) {
+	handler := newPolicyTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			_, _ = io.WriteString(w, testToolList)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = io.WriteString(gz, testToolList)
+		_ = gz.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := newFlushRecorder()
+	handler.ServeHTTP(rec, req)
+
+	body := rec.String()
+	if strings.Contains(body, "fs_delete") || !strings.Contains(body, `"search"`) {
+		t.Fatalf("denied tool listed for a gzip-accepting client: %q", body)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("decoded body sent with Content-Encoding %q", got)
+	}
+}
@@ -9,23 +9,35 @@ package proxy
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/authserver"
 	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/policy"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/tracing"
 )
 
+// headerMCPSessionID is the Streamable HTTP header used by MCP servers to
+// identify a client session after initialize.
+const headerMCPSessionID = "Mcp-Session-Id"
+
 // hopHeaders lists standard hop-by-hop headers that must be stripped before a
 // request is proxied so the upstream connection semantics remain correct.
 var hopHeaders = map[string]struct{}{
@@ -45,89 +57,330 @@ var hopHeaders = map[string]struct{}{
 type Proxy struct {
 	// cfg keeps runtime knobs such as the upstream URL and shared secrets.
 	cfg config.Config
-	// client performs outbound HTTP requests with tuned transport settings.
-	client *http.Client
-	// signer injects HMAC headers compatible with the upstream auth gateway.
-	signer *auth.Signer
+	// upstream is the default upstream; its fields are promoted for code
+	// that always talks to it, such as the readiness probe.
+	*upstream
+	// routes are the upstreams mounted under path prefixes, longest prefix
+	// first.
+	routes []*upstream
+	// aggregator merges every upstream behind one endpoint; nil disables
+	// aggregation.
+	aggregator *aggregator
 	// logger emits structured logs for observability.
 	logger zerolog.Logger
-	// baseURL is the parsed upstream address used to resolve inbound paths.
-	baseURL *url.URL
+	// authServer issues and validates local OAuth tokens when enabled.
+	authServer *authserver.Server
+	// inbound holds the *inboundAuth authenticating local clients; nil
+	// leaves the listener open. A reload replaces it.
+	inbound atomic.Pointer[inboundAuth]
+	// policy holds the *policy.Policy restricting which tools clients may
+	// list and call; nil allows all. A reload replaces it.
+	policy atomic.Pointer[policy.Policy]
+	// reloadMu serialises configuration reloads.
+	reloadMu sync.Mutex
+	// retry repeats failed idempotent upstream attempts; nil disables retries.
+	retry *retryPolicy
+	// limiter holds the *rateLimiter applying per-client token buckets; nil
+	// disables rate limiting. A reload that changes the limits replaces it.
+	limiter atomic.Pointer[rateLimiter]
+	// inflight holds one slot per upstream request in flight; nil leaves
+	// them unbounded.
+	inflight chan struct{}
+	// metrics records Prometheus instrumentation.
+	metrics *metrics.Metrics
+	// tracing owns the span exporter; tracer starts the proxy's spans.
+	tracing *tracing.Provider
+	tracer  trace.Tracer
+	// readiness caches the last upstream probe for /readyz.
+	readiness readiness
 }
 
 // New constructs a Proxy backed by an http.Client configured with sensible
 // connection pooling defaults and the provided runtime configuration.
 func New(cfg config.Config) (http.Handler, error) {
-	// Build a transport that honours system proxies and keeps connections warm.
-	transport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: cfg.InsecureSkipVerify, // nolint:gosec -- opt-in for development scenarios
-		},
+	return newProxy(cfg)
+}
+
+// newProxy builds the concrete Proxy shared by the HTTP handler and the stdio
+// bridge.
+func newProxy(cfg config.Config) (*Proxy, error) {
+	handler := &Proxy{
+		cfg:     cfg,
+		logger:  log.With().Str("component", "proxy").Logger(),
+		metrics: metrics.New(),
+		retry:   newRetryPolicy(cfg),
+	}
+	handler.limiter.Store(newRateLimiter(cfg))
+	if cfg.MaxConcurrentUpstream > 0 {
+		handler.inflight = make(chan struct{}, cfg.MaxConcurrentUpstream)
 	}
 
-	client := &http.Client{
-		Timeout:   cfg.RequestTimeout,
-		Transport: transport,
+	if cfg.AuthServerEnabled {
+		handler.authServer = authserver.New(authserver.Options{
+			Issuer:          cfg.AuthServerIssuer,
+			TokenTTL:        cfg.AuthServerTokenTTL,
+			RedirectSchemes: cfg.AuthServerRedirectSchemes,
+			// handler.inbound is set below, before any request is served.
+			Approve: func(r *http.Request) bool { return handler.inbound.Load().approveAuthServer(r) },
+		})
 	}
 
-	signer := auth.NewSigner(cfg.APIKey, cfg.APISecret)
+	var err error
+	if handler.upstream, err = handler.newUpstream(config.Route{Config: cfg}); err != nil {
+		return nil, err
+	}
+	if handler.breaker != nil {
+		handler.metrics.SetCircuitState(breakerClosed.String())
+	}
+	for _, route := range cfg.Routes {
+		u, err := handler.newUpstream(route)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.Name, err)
+		}
+		handler.routes = append(handler.routes, u)
+	}
+	if cfg.AggregatePath != "" {
+		handler.aggregator = newAggregator(cfg.AggregatePath, append([]*upstream{handler.upstream}, handler.routes...))
+	}
+	slices.SortStableFunc(handler.routes, func(a, b *upstream) int {
+		return len(b.prefix) - len(a.prefix)
+	})
 
-	handler := &Proxy{
-		cfg:     cfg,
-		client:  client,
-		signer:  signer,
-		logger:  log.With().Str("component", "proxy").Logger(),
-		baseURL: cloneURL(cfg.Upstream),
+	if handler.tracing, err = tracing.New(cfg); err != nil {
+		return nil, err
+	}
+	handler.tracer = handler.tracing.Tracer()
+
+	inbound, err := newInboundAuth(cfg, handler.authServer)
+	if err != nil {
+		return nil, err
+	}
+	handler.inbound.Store(inbound)
+
+	toolPolicy, err := loadToolPolicy(cfg)
+	if err != nil {
+		return nil, err
 	}
+	handler.policy.Store(toolPolicy)
 
 	return handler, nil
 }
 
-// ServeHTTP applies protocol-specific shortcuts (SSE fallback, discovery
-// responses) and otherwise streams the request/response pair to the upstream.
+// loadToolPolicy reads the tool policy cfg names, from a file or inline; it
+// returns nil when there is none.
+func loadToolPolicy(cfg config.Config) (*policy.Policy, error) {
+	switch {
+	case cfg.ToolPolicyFile != "":
+		return policy.Load(cfg.ToolPolicyFile)
+	case cfg.ToolPolicy != "":
+		toolPolicy, err := policy.Parse([]byte(cfg.ToolPolicy))
+		if err != nil {
+			return nil, fmt.Errorf("parse inline tool policy: %w", err)
+		}
+		return toolPolicy, nil
+	}
+	return nil, nil
+}
+
+// newAuthenticator selects the upstream auth strategy named by cfg.AuthMode.
+// tokenClient is used by strategies that call out to a token endpoint.
+func newAuthenticator(cfg config.Config, tokenClient *http.Client) (auth.RequestAuthenticator, error) {
+	switch cfg.AuthMode {
+	case "", auth.ModeHMAC:
+		signer := auth.NewSigner(cfg.APIKey, cfg.APISecret)
+		if cfg.SignatureVersion != "" {
+			signer.Version = cfg.SignatureVersion
+		}
+		signer.SignedHeaders = cfg.SignedHeaders
+		return signer, nil
+	case auth.ModeBearer:
+		return auth.NewStaticToken(cfg.BearerToken), nil
+	case auth.ModeOAuth2:
+		source := auth.NewClientCredentials(cfg.OAuthTokenURL, cfg.APIKey, cfg.APISecret, cfg.OAuthScopes, tokenClient)
+		source.Audience = cfg.OAuthAudience
+		if cfg.OAuthRefreshSkew > 0 {
+			source.RefreshSkew = cfg.OAuthRefreshSkew
+		}
+		return source, nil
+	case auth.ModeSigV4:
+		return auth.NewSigV4Signer(cfg.APIKey, cfg.APISecret, cfg.SigV4SessionToken, cfg.SigV4Region, cfg.SigV4Service), nil
+	default:
+		return nil, fmt.Errorf("unsupported auth mode %q", cfg.AuthMode)
+	}
+}
+
+// ServeHTTP records request metrics and the server span around serve.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	mw := &metricsWriter{ResponseWriter: w, metrics: p.metrics}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &countingBody{ReadCloser: r.Body, metrics: p.metrics}
+	}
+
+	r = withRequestID(r)
+	mw.Header().Set(headerRequestID, requestID(r.Context()))
+
+	r, span := p.startServerSpan(r)
+	stats := &requestStats{rpcMethod: "none"}
+	p.serve(mw, r, stats)
+	endSpan(span, mw.statusCode())
+	p.metrics.ObserveRequest(mw.statusCode(), stats.rpcMethod, time.Since(start))
+}
+
+// serve applies protocol-specific shortcuts (SSE fallback, discovery
+// responses) and otherwise streams the request/response pair to the upstream.
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, stats *requestStats) {
 	start := time.Now()
 	event := p.logger.With().
 		Str("method", r.Method).
 		Str("path", r.URL.Path).
 		Str("remote_addr", r.RemoteAddr).
+		Str("request_id", requestID(r.Context())).
 		Logger()
 
-	// Serve a local keep-alive stream when Codex expects SSE but the upstream
-	// does not expose one.
-	if r.Method == http.MethodGet && isEventStreamPath(r.URL.Path) {
-		p.serveEventStream(w, r, event)
+	// Liveness and readiness probes come from orchestrators without client
+	// credentials and must never reach the upstream.
+	if r.Method == http.MethodGet && isHealthPath(r.URL.Path) {
+		p.serveHealth(w, r)
+		return
+	}
+
+	// The local authorization server owns discovery, registration and token
+	// endpoints, which clients reach before they hold a token.
+	if p.authServer != nil && p.authServer.Handles(r.URL.Path) {
+		p.authServer.ServeHTTP(w, r)
+		event.Debug().Msg("served authorization server endpoint")
+		return
+	}
+
+	// Reject unauthenticated clients before anything is signed with our
+	// upstream credentials.
+	if inbound := p.inbound.Load(); inbound != nil {
+		identity, ok := inbound.authenticate(r)
+		if !ok {
+			inbound.challenge(w, r)
+			event.Warn().Msg("rejected unauthenticated client")
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+		event = event.With().Str("client", identity).Logger()
+	}
+
+	// Without an admin listener, metrics share the main listener and its
+	// inbound authentication.
+	if p.cfg.AdminAddr == "" && r.Method == http.MethodGet && r.URL.Path == PathMetrics {
+		p.metrics.Handler().ServeHTTP(w, r)
+		return
+	}
+
+	// Pick the upstream by path prefix; SSE and discovery paths are judged
+	// as the upstream will see them.
+	r, u := p.withUpstream(r)
+	if u.name != "" {
+		event = event.With().Str("route", u.name).Logger()
+	}
+	upstreamPath := u.upstreamPath(r.URL.Path)
+
+	// Record which JSON-RPC method, tool or resource the request targets.
+	payload, err := bufferRequestBody(r, event)
+	if err != nil {
+		p.writeForwardError(w, r, &httpError{Status: http.StatusBadRequest, Err: err}, event, start)
+		return
+	}
+	event = withRPCRequest(event.With(), payload).Logger()
+	stats.rpcMethod = rpcMethodLabel(payload)
+	trace.SpanFromContext(r.Context()).SetAttributes(rpcSpanAttributes(payload)...)
+
+	// Refuse payloads whose members could be read differently here and
+	// upstream, and tool calls the policy forbids, before anything is
+	// signed; remember tools/list requests whose results must be filtered.
+	if reply, denied := checkAmbiguousRPC(payload, requestID(r.Context()), event); denied {
+		writeLocalRPC(w, reply)
+		event.Info().Dur("duration", time.Since(start)).Msg("request answered by proxy")
+		return
+	}
+	if reply, denied := p.checkToolPolicy(payload, requestID(r.Context()), event); denied {
+		writeLocalRPC(w, reply)
+		event.Info().Dur("duration", time.Since(start)).Msg("request answered by tool policy")
+		return
+	}
+	if ids := toolListIDs(payload); p.policy.Load() != nil && ids != nil {
+		r = r.WithContext(context.WithValue(r.Context(), toolListKey{}, ids))
+	}
+
+	// Stop runaway clients before they spend upstream quota.
+	if limiter := p.limiter.Load(); limiter != nil {
+		if reason, wait, ok := limiter.take(limiter.clientKey(r, p.knownSession), payload); !ok {
+			p.writeRateLimited(w, r, payload, reason, wait, event, start)
+			return
+		}
+	}
+
+	// The aggregated endpoint fans out to every upstream itself.
+	if p.aggregator != nil && r.URL.Path == p.aggregator.path {
+		p.serveAggregate(w, r, payload, event, start)
+		return
+	}
+
+	// Relay the upstream event stream when configured; otherwise serve a local
+	// keep-alive stream when Codex expects SSE but the upstream does not
+	// expose one.
+	if r.Method == http.MethodGet && isEventStreamPath(upstreamPath) {
+		r, missed := p.resumeEventStream(r, event)
+		if u.cfg.SSEMode == config.SSEModeUpstream {
+			p.relayEventStream(w, r, event, start, missed)
+			return
+		}
+		p.serveEventStream(w, r, event, missed)
 		return
 	}
 
 	// Respond locally for discovery metadata probes to avoid noisy upstream 404s.
-	if r.Method == http.MethodGet && isDiscoveryPath(r.URL.Path) {
+	if r.Method == http.MethodGet && isDiscoveryPath(upstreamPath) {
 		p.serveDiscovery(w, r, event)
 		return
 	}
 
+	// GET event streams were served above and take no slot: they would hold
+	// one for hours.
+	release, ok := p.acquireUpstream(1)
+	if !ok {
+		p.writeRateLimited(w, r, payload, metrics.RateLimitConcurrency, time.Second, event, start)
+		return
+	}
+	defer release()
+
 	resp, err := p.forwardRequest(r, event)
+	if errors.Is(err, errCircuitOpen) {
+		p.writeCircuitOpen(w, r, payload, event, start)
+		return
+	}
 	if err != nil {
-		status := http.StatusBadGateway
-		var httpErr *httpError
-		if errors.As(err, &httpErr) {
-			status = httpErr.Status
-		}
-		http.Error(w, http.StatusText(status), status)
-		event.Error().
-			Err(err).
-			Dur("duration", time.Since(start)).
-			Msg("request failed")
+		p.writeForwardError(w, r, err, event, start)
 		return
 	}
 
+	p.writeResponse(w, r, resp, event, start)
+}
+
+// writeForwardError maps a failed upstream round trip to a local error response.
+func (p *Proxy) writeForwardError(w http.ResponseWriter, r *http.Request, err error, event zerolog.Logger, start time.Time) {
+	status := http.StatusBadGateway
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Status
+	}
+	writeLocalError(w, r, http.StatusText(status), status)
+	event.Error().
+		Err(err).
+		Dur("duration", time.Since(start)).
+		Msg("request failed")
+}
+
+// writeResponse copies the upstream status, headers and body to the client
+// and closes the upstream body. Event stream bodies are relayed event by
+// event instead of being copied in bulk.
+func (p *Proxy) writeResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, event zerolog.Logger, start time.Time) {
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
 			event.Error().
@@ -136,6 +389,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	if resp.StatusCode < http.StatusBadRequest && isEventStreamResponse(resp) {
+		p.writeEventStream(w, r, resp, event, start)
+		return
+	}
+
 	// Default to streaming the upstream body unless we need to inspect errors.
 	var bodyReader io.Reader = resp.Body
 	if resp.StatusCode >= http.StatusBadRequest {
@@ -147,7 +405,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				Int("status", resp.StatusCode).
 				Msg("failed to read upstream error body")
 		} else {
-			event.Warn().
+			logRPCErrors(event.Warn(), rpcErrorCodes(payload)).
 				Int("status", resp.StatusCode).
 				Bytes("upstream_body", payload).
 				Msg("upstream returned error")
@@ -155,10 +413,23 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Hide tools the policy refuses; this may change the body length, so it
+	// runs before the headers are copied.
+	if ids := toolListFilter(r.Context()); ids != nil && resp.StatusCode < http.StatusBadRequest && isJSONResponse(resp) {
+		bodyReader = p.filterJSONToolList(resp, ids, event)
+	}
+
 	cleanHopHeaders(resp.Header)
 	copyResponseHeaders(w.Header(), resp.Header)
 	w.WriteHeader(resp.StatusCode)
 
+	// Keep a bounded copy of JSON answers to log their JSON-RPC error codes.
+	var capture *captureBuffer
+	if resp.StatusCode < http.StatusBadRequest && isJSONResponse(resp) {
+		capture = &captureBuffer{limit: maxInspectBody}
+		bodyReader = io.TeeReader(bodyReader, capture)
+	}
+
 	if _, copyErr := io.Copy(w, bodyReader); copyErr != nil {
 		event.Error().
 			Err(copyErr).
@@ -167,14 +438,45 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	event.Info().
+	var codes []int
+	if capture != nil {
+		codes = capture.errorCodes()
+	}
+	logRPCErrors(event.Info(), codes).
+		Int("status", resp.StatusCode).
 		Dur("duration", time.Since(start)).
 		Msg("request proxied")
 }
 
-// forwardRequest clones the inbound request, augments headers, signs it, and
-// returns the upstream response for the caller to stream back.
+// forwardRequest maps the client's Mcp-Session-Id to the upstream one, sends
+// the request within a client span, and records any session the upstream
+// issues in the response. The span ends once the response headers arrive.
 func (p *Proxy) forwardRequest(r *http.Request, event zerolog.Logger) (*http.Response, error) {
+	u := p.upstreamFor(r.Context())
+	r, err := u.sessions.inbound(r)
+	if err != nil {
+		return nil, err
+	}
+
+	r, span := p.startClientSpan(r)
+	resp, err := p.sendRequest(r, event)
+	if err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+	endSpan(span, resp.StatusCode)
+
+	// The proxy's request id is authoritative; drop any upstream echo so the
+	// client sees a single value.
+	resp.Header.Del(headerRequestID)
+	u.sessions.outbound(r, resp)
+	return resp, nil
+}
+
+// sendRequest buffers the inbound body and sends it upstream, repeating
+// failed attempts the retry policy allows. Each attempt is signed afresh, so
+// every retry carries a new timestamp.
+func (p *Proxy) sendRequest(r *http.Request, event zerolog.Logger) (*http.Response, error) {
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, fmt.Errorf("read request body: %w", err)
@@ -187,7 +489,99 @@ func (p *Proxy) forwardRequest(r *http.Request, event zerolog.Logger) (*http.Res
 		}
 	}()
 
-	targetURL := p.singleJoiningURL(r.URL)
+	retry := p.retry
+	if retry != nil && !retry.allows(r, bodyBytes) {
+		retry = nil
+	}
+	if retry != nil && retry.budget != nil {
+		retry.budget.deposit()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := p.sendAttempt(r, bodyBytes, event)
+		if retry == nil || attempt >= retry.maxAttempts || r.Context().Err() != nil {
+			return resp, err
+		}
+		wait, ok := retry.backoff(attempt, resp, err)
+		if !ok {
+			return resp, err
+		}
+		if retry.budget != nil && !retry.budget.withdraw() {
+			event.Warn().Int("attempt", attempt).Msg("retry budget exhausted; not retrying upstream request")
+			return resp, err
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			if closeErr := resp.Body.Close(); closeErr != nil {
+				event.Error().Err(closeErr).Msg("close retried upstream response failed")
+			}
+		}
+		event.Warn().
+			Err(err).
+			Int("status", status).
+			Int("attempt", attempt).
+			Dur("backoff", wait).
+			Msg("retrying upstream request")
+		p.metrics.UpstreamRetry()
+		trace.SpanFromContext(r.Context()).AddEvent("retry", trace.WithAttributes(
+			semconv.HTTPRequestResendCount(attempt),
+			semconv.HTTPResponseStatusCode(status),
+		))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, &httpError{Status: http.StatusGatewayTimeout, Err: r.Context().Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// sendAttempt signs and sends one upstream attempt. When the upstream rejects
+// a renewable credential with 401 the request is re-signed with a fresh
+// credential and sent once more.
+func (p *Proxy) sendAttempt(r *http.Request, bodyBytes []byte, event zerolog.Logger) (*http.Response, error) {
+	upstreamReq, err := p.newUpstreamRequest(r, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.doWithDeadline(upstreamReq)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidator, ok := p.targetFor(r.Context()).authenticator.(auth.TokenInvalidator)
+	if !ok || resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	// Discard the rejection and retry once with a newly minted credential.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if err := resp.Body.Close(); err != nil {
+		event.Error().Err(err).Msg("close rejected upstream response failed")
+	}
+	invalidator.Invalidate(upstreamReq)
+	event.Warn().Msg("upstream rejected credential; retrying with a fresh token")
+
+	retryReq, err := p.newUpstreamRequest(r, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return p.doWithDeadline(retryReq)
+}
+
+// newUpstreamRequest builds a signed request for the upstream selected on r
+// from the inbound one and its buffered body. It is safe to call repeatedly
+// for retries.
+func (p *Proxy) newUpstreamRequest(r *http.Request, bodyBytes []byte) (*http.Request, error) {
+	u := p.upstreamFor(r.Context())
+	target := p.targetFor(r.Context())
+	targetURL := u.singleJoiningURL(target.baseURL, r.URL)
 
 	upstreamReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), bytes.NewReader(bodyBytes))
 	if err != nil {
@@ -196,42 +590,118 @@ func (p *Proxy) forwardRequest(r *http.Request, event zerolog.Logger) (*http.Res
 
 	copyHeaders(upstreamReq.Header, r.Header)
 	cleanHopHeaders(upstreamReq.Header)
+	// The proxy inspects and rewrites response bodies (tool policy, error
+	// codes, aggregation), so it must see them decoded. Without the client's
+	// Accept-Encoding the transport negotiates gzip itself and decompresses
+	// transparently.
+	upstreamReq.Header.Del("Accept-Encoding")
 	augmentForwardHeaders(upstreamReq.Header, r)
+	if id := requestID(r.Context()); id != "" {
+		upstreamReq.Header.Set(headerRequestID, id)
+	}
+	injectTraceContext(r, upstreamReq.Header)
+
+	for name, value := range target.headers {
+		upstreamReq.Header.Set(name, value)
+	}
 
-	if p.cfg.SessionValue != "" {
+	if target.sessionValue != "" {
 		// Attach the session header so the upstream can associate the call with an authenticated user.
-		upstreamReq.Header.Set(p.cfg.SessionHeader, p.cfg.SessionValue)
+		upstreamReq.Header.Set(target.sessionHeader, target.sessionValue)
 	}
 
 	upstreamReq.Host = targetURL.Host
 
-	if err := p.signer.AttachSignature(upstreamReq); err != nil {
+	if err := target.authenticator.Authenticate(upstreamReq, bodyBytes); err != nil {
 		return nil, fmt.Errorf("sign request: %w", err)
 	}
 
-	resp, err := p.client.Do(upstreamReq)
+	return upstreamReq, nil
+}
+
+// doWithDeadline performs the round trip bounded by the upstream's
+// RequestTimeout. The deadline keeps running while the body is read, unless
+// the caller switches the returned body to stream mode with markStreaming.
+func (p *Proxy) doWithDeadline(upstreamReq *http.Request) (*http.Response, error) {
+	timeout := p.upstreamFor(upstreamReq.Context()).cfg.RequestTimeout
+	if timeout <= 0 {
+		return p.do(upstreamReq)
+	}
+
+	ctx, cancel := context.WithCancelCause(upstreamReq.Context())
+	timer := time.AfterFunc(timeout, func() { cancel(errRequestTimeout) })
+
+	resp, err := p.do(upstreamReq.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel(nil)
+		return nil, err
+	}
+
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, timer: timer, cancel: cancel}
+	return resp, nil
+}
+
+// do performs the upstream round trip through the circuit breaker, maps
+// timeouts to 504 responses, and counts upstream failures by class.
+// Cancellations caused by the client going away are not counted. Readiness
+// probes bypass the breaker: they neither wait for it nor trip it.
+func (p *Proxy) do(upstreamReq *http.Request) (*http.Response, error) {
+	breaker := p.upstreamFor(upstreamReq.Context()).breaker
+	if isReadinessProbe(upstreamReq.Context()) {
+		breaker = nil
+	}
+	if breaker != nil && !breaker.allow() {
+		p.metrics.CircuitRejected()
+		return nil, errCircuitOpen
+	}
+
+	resp, err := p.upstreamFor(upstreamReq.Context()).client.Do(upstreamReq)
 	if err != nil {
 		switch {
-		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		case errors.Is(context.Cause(upstreamReq.Context()), errRequestTimeout), errors.Is(err, context.DeadlineExceeded):
+			p.upstreamFailed(breaker, metrics.ErrorTimeout)
+			return nil, &httpError{Status: http.StatusGatewayTimeout, Err: err}
+		case errors.Is(err, context.Canceled):
+			if breaker != nil {
+				breaker.release()
+			}
 			return nil, &httpError{Status: http.StatusGatewayTimeout, Err: err}
 		default:
 			var netErr net.Error
 			if errors.As(err, &netErr); netErr != nil && netErr.Timeout() {
+				p.upstreamFailed(breaker, metrics.ErrorTimeout)
 				return nil, &httpError{Status: http.StatusGatewayTimeout, Err: err}
 			}
 		}
+		p.upstreamFailed(breaker, metrics.ErrorNetwork)
 		return nil, fmt.Errorf("perform upstream request: %w", err)
 	}
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		p.upstreamFailed(breaker, metrics.ErrorServer)
+	} else if breaker != nil {
+		breaker.record(false)
+	}
 	return resp, nil
 }
 
+// upstreamFailed counts a failed round trip of the given class and reports
+// it to the circuit breaker, if any.
+func (p *Proxy) upstreamFailed(breaker *circuitBreaker, class string) {
+	p.metrics.UpstreamError(class)
+	if breaker != nil {
+		breaker.record(true)
+	}
+}
+
 // serveEventStream returns a minimal text/event-stream response with periodic
-// keep-alive messages so MCP clients can complete their handshake.
-func (p *Proxy) serveEventStream(w http.ResponseWriter, r *http.Request, event zerolog.Logger) {
+// keep-alive messages so MCP clients can complete their handshake. Frames in
+// missed are replayed right after the opening comment.
+func (p *Proxy) serveEventStream(w http.ResponseWriter, r *http.Request, event zerolog.Logger, missed [][]byte) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		writeLocalError(w, r, "streaming unsupported", http.StatusInternalServerError)
 		event.Error().Msg("response writer does not support flushing for SSE")
 		return
 	}
@@ -239,11 +709,17 @@ func (p *Proxy) serveEventStream(w http.ResponseWriter, r *http.Request, event z
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
+	clearWriteDeadline(w)
 
 	if _, err := io.WriteString(w, ":ok\n\n"); err != nil {
 		event.Error().Err(err).Msg("failed to send initial SSE comment")
 		return
 	}
+	defer p.metrics.StreamOpened(metrics.StreamLocal)()
+	if err := writeFrames(w, missed); err != nil {
+		event.Error().Err(err).Msg("failed to replay missed events")
+		return
+	}
 	flusher.Flush()
 
 	ticker := time.NewTicker(25 * time.Second)
@@ -267,8 +743,9 @@ func (p *Proxy) serveEventStream(w http.ResponseWriter, r *http.Request, event z
 	}
 }
 
-// serveDiscovery currently returns a 404 placeholder so OIDC discovery probes
-// do not propagate confusing upstream errors.
+// serveDiscovery returns a 404 placeholder when the local authorization server
+// is disabled so OAuth discovery probes do not propagate confusing upstream
+// errors.
 func (p *Proxy) serveDiscovery(w http.ResponseWriter, r *http.Request, event zerolog.Logger) {
 	http.NotFound(w, r)
 	event.Debug().Msg("discovery metadata not available; returning 404")
@@ -285,19 +762,8 @@ func isEventStreamPath(path string) bool {
 
 // isDiscoveryPath identifies well-known OAuth discovery URL probes.
 func isDiscoveryPath(path string) bool {
-	return strings.HasPrefix(path, "/.well-known/oauth-authorization-server")
-}
-
-// singleJoiningURL resolves the incoming path relative to the configured base.
-func (p *Proxy) singleJoiningURL(requestURL *url.URL) *url.URL {
-	ref := &url.URL{
-		Path:     requestURL.Path,
-		RawPath:  requestURL.RawPath,
-		RawQuery: requestURL.RawQuery,
-		Fragment: requestURL.Fragment,
-	}
-	target := p.baseURL.ResolveReference(ref)
-	return target
+	return strings.HasPrefix(path, authserver.PathAuthorizationServerMetadata) ||
+		strings.HasPrefix(path, authserver.PathProtectedResourceMetadata)
 }
 
 // cloneURL makes a shallow copy of the provided URL pointer.
@@ -8,7 +8,9 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +18,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -64,7 +67,11 @@ func TestProxyForwardsAndSignsRequests(t *testing.T) {
 
 	// Fix the timestamp so the signature can be asserted.
 	fixedNow := time.Unix(1700000000, 0).UTC()
-	p.signer.Now = func() time.Time { return fixedNow }
+	signer, ok := p.current().authenticator.(*auth.Signer)
+	if !ok {
+		t.Fatalf("expected HMAC signer by default, got %T", p.current().authenticator)
+	}
+	signer.Now = func() time.Time { return fixedNow }
 
 	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 		body, err := io.ReadAll(req.Body)
@@ -123,6 +130,208 @@ func TestProxyForwardsAndSignsRequests(t *testing.T) {
 	}
 }
 
+func TestProxySignsBodyDigestWithV2(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:         upstreamURL,
+		APIKey:           "key-id",
+		APISecret:        "secret-value",
+		RequestTimeout:   time.Second,
+		SignatureVersion: auth.SignatureV2,
+		SignedHeaders:    []string{"content-type"},
+	}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p, ok := handler.(*Proxy)
+	if !ok {
+		t.Fatalf("expected *Proxy, got %T", handler)
+	}
+
+	var receivedHeader http.Header
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		receivedHeader = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp?debug=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if got := receivedHeader.Get(auth.HeaderSignatureVersion); got != auth.SignatureV2 {
+		t.Fatalf("expected v2 signature version header, got %q", got)
+	}
+	if got := receivedHeader.Get(auth.HeaderContentSHA256); got != auth.BodyDigest([]byte(body)) {
+		t.Fatalf("body digest mismatch: %q", got)
+	}
+	if got := receivedHeader.Get(auth.HeaderSignedHeaders); got != "content-type" {
+		t.Fatalf("unexpected signed headers: %q", got)
+	}
+}
+
+func TestProxyUsesConfiguredAuthMode(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:       upstreamURL,
+		AuthMode:       auth.ModeBearer,
+		BearerToken:    "upstream-token",
+		RequestTimeout: time.Second,
+	}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p, ok := handler.(*Proxy)
+	if !ok {
+		t.Fatalf("expected *Proxy, got %T", handler)
+	}
+
+	var receivedHeader http.Header
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		receivedHeader = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer client-token")
+	rec := httptest.NewRecorder()
+
+	p.ServeHTTP(rec, req)
+
+	if got := receivedHeader.Get("Authorization"); got != "Bearer upstream-token" {
+		t.Fatalf("unexpected authorization header %q", got)
+	}
+	if got := receivedHeader.Get(auth.HeaderSignature); got != "" {
+		t.Fatalf("bearer mode should not add HMAC headers, got %q", got)
+	}
+
+	cfg.AuthMode = "kerberos"
+	if _, err := New(cfg); err == nil {
+		t.Fatal("expected error for unsupported auth mode")
+	}
+}
+
+func TestProxyRetriesOnceWithFreshOAuthToken(t *testing.T) {
+	var tokenCalls int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokenCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:       upstreamURL,
+		APIKey:         "client-id",
+		APISecret:      "client-secret",
+		AuthMode:       auth.ModeOAuth2,
+		OAuthTokenURL:  tokenServer.URL,
+		RequestTimeout: time.Second,
+	}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p, ok := handler.(*Proxy)
+	if !ok {
+		t.Fatalf("expected *Proxy, got %T", handler)
+	}
+
+	var (
+		upstreamCalls int32
+		bodies        []string
+	)
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, string(body))
+		if req.Header.Get("Authorization") != "Bearer token-2" {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader("expired")),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"id":1}`))
+	rec := httptest.NewRecorder()
+
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected retry to succeed, got %d", rec.Code)
+	}
+	if got := atomic.LoadInt32(&upstreamCalls); got != 2 {
+		t.Fatalf("expected exactly one retry, got %d upstream calls", got)
+	}
+	for _, body := range bodies {
+		if body != `{"id":1}` {
+			t.Fatalf("retry should replay the original body, got %q", body)
+		}
+	}
+
+	// A second rejection is surfaced instead of retrying indefinitely.
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("denied")),
+		}, nil
+	})
+	atomic.StoreInt32(&upstreamCalls, 0)
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{}`)))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after failed retry, got %d", rec.Code)
+	}
+	if got := atomic.LoadInt32(&upstreamCalls); got != 2 {
+		t.Fatalf("expected a single retry, got %d upstream calls", got)
+	}
+}
+
 func TestProxyServeEventStreamFallback(t *testing.T) {
 	var outboundCalls int32
 
@@ -172,7 +381,7 @@ func TestProxyServeEventStreamFallback(t *testing.T) {
 	}()
 
 	waitUntil(t, 500*time.Millisecond, func() bool {
-		return strings.Contains(rec.body.String(), ":ok")
+		return strings.Contains(rec.String(), ":ok")
 	})
 
 	cancel()
@@ -246,6 +455,117 @@ func TestProxyDiscoveryReturnsNotFound(t *testing.T) {
 	}
 }
 
+func TestProxyLocalAuthorizationServer(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:          upstreamURL,
+		APIKey:            "key-id",
+		APISecret:         "secret-value",
+		RequestTimeout:    time.Second,
+		AuthServerEnabled: true,
+	}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p, ok := handler.(*Proxy)
+	if !ok {
+		t.Fatalf("expected *Proxy, got %T", handler)
+	}
+
+	var receivedHeader http.Header
+	p.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		receivedHeader = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy/.well-known/oauth-authorization-server", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected metadata, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader("{}")))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+	wantChallenge := `Bearer resource_metadata="http://proxy/.well-known/oauth-protected-resource"`
+	if got := rec.Header().Get("WWW-Authenticate"); got != wantChallenge {
+		t.Fatalf("unexpected challenge: %q", got)
+	}
+	if receivedHeader != nil {
+		t.Fatal("unauthenticated request reached upstream")
+	}
+
+	// Register, authorize and exchange a code to obtain a token.
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/register",
+		strings.NewReader(`{"redirect_uris":["http://127.0.0.1:9999/cb"]}`)))
+	var registration struct {
+		ClientID string `json:"client_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &registration); err != nil {
+		t.Fatalf("decode registration: %v", err)
+	}
+
+	verifier := strings.Repeat("v", 64)
+	sum := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {registration.ClientID},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy/authorize?"+query.Encode(), nil))
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {registration.ClientID},
+		"code":          {location.Query().Get("code")},
+		"code_verifier": {verifier},
+	}
+	tokenReq := httptest.NewRequest(http.MethodPost, "http://proxy/token", strings.NewReader(form.Encode()))
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, tokenReq)
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil || tokens.AccessToken == "" {
+		t.Fatalf("token exchange failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected authorized request to succeed, got %d", rec.Code)
+	}
+	if got := receivedHeader.Get("Authorization"); got != "" {
+		t.Fatalf("local token leaked upstream: %q", got)
+	}
+	if got := receivedHeader.Get(auth.HeaderSignature); got == "" {
+		t.Fatal("expected upstream request to be signed")
+	}
+}
+
 func TestProxyPropagatesErrorBodies(t *testing.T) {
 	upstreamURL, err := url.Parse("https://upstream.example.com")
 	if err != nil {
@@ -306,9 +626,11 @@ func computeSignature(secret, method, path, timestamp string) string {
 }
 
 type flushRecorder struct {
-	header http.Header
-	status int
-	body   bytes.Buffer
+	mu      sync.Mutex
+	header  http.Header
+	status  int
+	body    bytes.Buffer
+	flushes int
 }
 
 func newFlushRecorder() *flushRecorder {
@@ -322,17 +644,31 @@ func (r *flushRecorder) Header() http.Header {
 }
 
 func (r *flushRecorder) WriteHeader(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.status = status
 }
 
 func (r *flushRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.status == 0 {
 		r.status = http.StatusOK
 	}
 	return r.body.Write(b)
 }
 
-func (r *flushRecorder) Flush() {}
+func (r *flushRecorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushes++
+}
+
+func (r *flushRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body.String()
+}
 
 func waitUntil(t *testing.T, timeout time.Duration, fn func() bool) {
 	t.Helper()
@@ -0,0 +1,309 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"encoding/json"
+	"maps"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+// rpcCodeRateLimited is the implementation-defined JSON-RPC server error sent
+// with 429 answers.
+const rpcCodeRateLimited = -32001
+
+// rateLimitSweepInterval is how often buckets that have refilled completely
+// are dropped; a full bucket is indistinguishable from a new one.
+const rateLimitSweepInterval = time.Minute
+
+// bucketKey names one token bucket: a client and the limit it is charged to.
+type bucketKey struct {
+	client string
+	// scope is the metrics.RateLimit* reason the bucket enforces.
+	scope string
+	// name is the method or tool pattern; empty for the per-client limit.
+	name string
+}
+
+// tokenBucket holds the tokens left in a bucket as of updated.
+type tokenBucket struct {
+	limit   config.RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*b.limit.Rate, float64(b.limit.Burst))
+		b.updated = now
+	}
+}
+
+// rateLimiter enforces token-bucket limits per client: one on every request
+// and optional ones per JSON-RPC method and per tool.
+type rateLimiter struct {
+	// keyMode is a config.RateLimitKey* value.
+	keyMode string
+	client  config.RateLimit
+	methods map[string]config.RateLimit
+	// tools holds exact tool names; toolPatterns the glob patterns among
+	// them, sorted so matching is deterministic.
+	tools        map[string]config.RateLimit
+	toolPatterns []string
+	// now is the clock; tests replace it.
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+// newRateLimiter returns the limiter configured in cfg, or nil when no rate
+// limit is set.
+func newRateLimiter(cfg config.Config) *rateLimiter {
+	l := &rateLimiter{
+		keyMode: cfg.RateLimitKey,
+		client:  cfg.ClientRateLimit,
+		methods: make(map[string]config.RateLimit),
+		tools:   make(map[string]config.RateLimit),
+		now:     time.Now,
+		buckets: make(map[bucketKey]*tokenBucket),
+	}
+	for method, limit := range cfg.MethodRateLimits {
+		if limit.Rate > 0 {
+			l.methods[method] = limit
+		}
+	}
+	for tool, limit := range cfg.ToolRateLimits {
+		if limit.Rate <= 0 {
+			continue
+		}
+		l.tools[tool] = limit
+		if isGlob(tool) {
+			l.toolPatterns = append(l.toolPatterns, tool)
+		}
+	}
+	slices.Sort(l.toolPatterns)
+
+	if l.client.Rate <= 0 && len(l.methods) == 0 && len(l.tools) == 0 {
+		return nil
+	}
+	return l
+}
+
+// sameLimits reports whether l and other enforce the same limits; either
+// may be nil.
+func (l *rateLimiter) sameLimits(other *rateLimiter) bool {
+	if l == nil || other == nil {
+		return l == other
+	}
+	return l.keyMode == other.keyMode && l.client == other.client &&
+		maps.Equal(l.methods, other.methods) && maps.Equal(l.tools, other.tools)
+}
+
+// clientKey identifies the client a request is charged to. Session ids count
+// only when known reports them as issued, so clients cannot earn fresh
+// buckets by making ids up; other requests are charged to their IP.
+func (l *rateLimiter) clientKey(r *http.Request, known func(r *http.Request, id string) bool) string {
+	switch l.keyMode {
+	case config.RateLimitKeyIP:
+	case config.RateLimitKeySession:
+		if id := r.Header.Get(headerMCPSessionID); id != "" && known(r, id) {
+			return "session:" + id
+		}
+	default:
+		if id := clientIdentity(r.Context()); id != "" {
+			return "client:" + id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// take charges one token per JSON-RPC message in payload (at least one per
+// request) to every bucket the request falls under. Tokens are only spent
+// when all buckets can pay; otherwise take returns the refusing limit's reason
+// and how long until it could. Payloads checkRPCKeys rejects are refused
+// before take runs, so the method and tool charged are the ones forwarded.
+func (l *rateLimiter) take(client string, payload []byte) (string, time.Duration, bool) {
+	costs := make(map[bucketKey]float64)
+	limits := make(map[bucketKey]config.RateLimit)
+	charge := func(scope, name string, limit config.RateLimit) {
+		key := bucketKey{client: client, scope: scope, name: name}
+		costs[key]++
+		limits[key] = limit
+	}
+
+	msgs, _ := decodeRPC(payload)
+	if l.client.Rate > 0 {
+		key := bucketKey{client: client, scope: metrics.RateLimitClient}
+		costs[key] = float64(max(len(msgs), 1))
+		limits[key] = l.client
+	}
+	for i := range msgs {
+		if limit, ok := l.methods[msgs[i].Method]; ok {
+			charge(metrics.RateLimitMethod, msgs[i].Method, limit)
+		}
+		if msgs[i].Method != "tools/call" {
+			continue
+		}
+		var params rpcCallParams
+		if err := json.Unmarshal(msgs[i].Params, &params); err != nil {
+			continue
+		}
+		if name, limit, ok := l.toolLimit(params.Name); ok {
+			charge(metrics.RateLimitTool, name, limit)
+		}
+	}
+	if len(costs) == 0 {
+		return "", 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweepLocked(now)
+
+	reason, wait := "", time.Duration(0)
+	buckets := make(map[bucketKey]*tokenBucket, len(costs))
+	for key, cost := range costs {
+		bucket := l.buckets[key]
+		if bucket == nil {
+			bucket = &tokenBucket{limit: limits[key], tokens: float64(limits[key].Burst), updated: now}
+		}
+		bucket.refill(now)
+		buckets[key] = bucket
+		if bucket.tokens >= cost {
+			continue
+		}
+		// A request costing more than the burst can never pass; report the
+		// time to refill the bucket completely.
+		missing := min(cost, float64(bucket.limit.Burst)) - bucket.tokens
+		if need := time.Duration(missing / bucket.limit.Rate * float64(time.Second)); need >= wait {
+			reason, wait = key.scope, need
+		}
+	}
+	if reason != "" {
+		return reason, wait, false
+	}
+	for key, bucket := range buckets {
+		bucket.tokens -= costs[key]
+		l.buckets[key] = bucket
+	}
+	return "", 0, true
+}
+
+// toolLimit returns the limit for a tool name and the name of the bucket it
+// is charged to: the exact name when configured, otherwise the first
+// matching pattern.
+func (l *rateLimiter) toolLimit(name string) (string, config.RateLimit, bool) {
+	if limit, ok := l.tools[name]; ok {
+		return name, limit, true
+	}
+	for _, pattern := range l.toolPatterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, l.tools[pattern], true
+		}
+	}
+	return "", config.RateLimit{}, false
+}
+
+// sweepLocked drops buckets that have refilled completely; l.mu must be held.
+func (l *rateLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.refill(now); bucket.tokens >= float64(bucket.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// isGlob reports whether a tool name uses path.Match syntax.
+func isGlob(name string) bool {
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
+
+// knownSession reports whether id is a session issued for the endpoint r
+// targets: by the upstream, or by the proxy on the aggregated endpoint.
+func (p *Proxy) knownSession(r *http.Request, id string) bool {
+	if p.aggregator != nil && r.URL.Path == p.aggregator.path {
+		_, ok := p.aggregator.session(id)
+		return ok
+	}
+	return p.upstreamFor(r.Context()).sessions.issued(id)
+}
+
+// acquireUpstream reserves n of the MaxConcurrentUpstream slots without
+// waiting. It returns the function that frees them, or false, holding none,
+// when fewer than n are free.
+func (p *Proxy) acquireUpstream(n int) (func(), bool) {
+	if p.inflight == nil {
+		return func() {}, true
+	}
+	release := func(held int) {
+		for range held {
+			<-p.inflight
+		}
+	}
+	for held := range n {
+		select {
+		case p.inflight <- struct{}{}:
+		default:
+			release(held)
+			return nil, false
+		}
+	}
+	return func() { release(n) }, true
+}
+
+// writeRateLimited answers a request refused by a rate limit or the
+// concurrency cap with 429, Retry-After and a JSON-RPC error for every request
+// in payload, or a single error with a null id when there is none.
+func (p *Proxy) writeRateLimited(w http.ResponseWriter, r *http.Request, payload []byte, reason string, wait time.Duration, event zerolog.Logger, start time.Time) {
+	message := "rate limit exceeded"
+	if reason == metrics.RateLimitConcurrency {
+		message = "too many upstream requests in flight"
+	}
+	reply := rpcErrorReplies(payload, rpcCodeRateLimited, message, requestID(r.Context()))
+	if reply == nil {
+		reply = newRPCError(nil, rpcCodeRateLimited, message, requestID(r.Context()))
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(int((wait+time.Second-1)/time.Second), 1)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = w.Write(reply)
+
+	p.metrics.RateLimited(reason)
+	event.Warn().
+		Str("limit", reason).
+		Dur("retry_after", wait).
+		Dur("duration", time.Since(start)).
+		Msg("request rate limited")
+}
@@ -0,0 +1,229 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+func TestRateLimiterBuckets(t *testing.T) {
+	l := newRateLimiter(config.Config{
+		ClientRateLimit:  config.RateLimit{Rate: 1, Burst: 3},
+		MethodRateLimits: map[string]config.RateLimit{"tools/list": {Rate: 0.5, Burst: 1}},
+		ToolRateLimits:   map[string]config.RateLimit{"fs_*": {Rate: 1, Burst: 1}},
+	})
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return clock }
+
+	steps := []struct {
+		name    string
+		client  string
+		payload string
+		advance time.Duration
+		reason  string
+		wait    time.Duration
+	}{
+		{name: "method bucket", client: "a", payload: testListRequest},
+		{name: "method exhausted", client: "a", payload: testListRequest, reason: metrics.RateLimitMethod, wait: 2 * time.Second},
+		{name: "tool pattern", client: "a", payload: `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fs_read"}}`},
+		{name: "pattern shared across tools", client: "a", payload: `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"fs_write"}}`, reason: metrics.RateLimitTool, wait: time.Second},
+		{name: "unlimited tool", client: "a", payload: testCallRequest},
+		{name: "client exhausted", client: "a", payload: testCallRequest, reason: metrics.RateLimitClient, wait: time.Second},
+		{name: "other client", client: "b", payload: testCallRequest},
+		{name: "batch larger than refill", client: "a", advance: time.Second, payload: "[" + testCallRequest + "," + testCallRequest + "]", reason: metrics.RateLimitClient, wait: time.Second},
+		{name: "refilled", client: "a", advance: time.Second, payload: "[" + testCallRequest + "," + testCallRequest + "]"},
+	}
+	for _, step := range steps {
+		clock = clock.Add(step.advance)
+		reason, wait, ok := l.take(step.client, []byte(step.payload))
+		if reason != step.reason || wait != step.wait || ok != (step.reason == "") {
+			t.Fatalf("%s: take = %q, %v, %v; want %q, %v", step.name, reason, wait, ok, step.reason, step.wait)
+		}
+	}
+
+	clock = clock.Add(time.Hour)
+	l.take("c", []byte(testCallRequest))
+	if len(l.buckets) != 1 {
+		t.Fatalf("idle buckets were not swept: %d left", len(l.buckets))
+	}
+}
+
+func TestRateLimiterClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", nil)
+	req.RemoteAddr = "192.0.2.7:41000"
+	req.Header.Set(headerMCPSessionID, "sess-1")
+
+	tests := []struct {
+		mode  string
+		known bool
+		want  string
+	}{
+		{mode: config.RateLimitKeyIdentity, want: "ip:192.0.2.7"},
+		{mode: config.RateLimitKeyIP, want: "ip:192.0.2.7"},
+		{mode: config.RateLimitKeySession, known: true, want: "session:sess-1"},
+		{mode: config.RateLimitKeySession, known: false, want: "ip:192.0.2.7"},
+	}
+	for _, tc := range tests {
+		l := &rateLimiter{keyMode: tc.mode}
+		known := func(*http.Request, string) bool { return tc.known }
+		if got := l.clientKey(req, known); got != tc.want {
+			t.Errorf("%s: clientKey = %q, want %q", tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestProxyRateLimitsClients(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.ClientRateLimit = config.RateLimit{Rate: 0.25, Burst: 1}
+	cfg.InboundToken = "client-token"
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	call := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer client-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := call(testCallRequest); rec.Code != http.StatusOK {
+		t.Fatalf("first request should pass, got %d", rec.Code)
+	}
+
+	rec := call(testCallRequest)
+	var reply rpcErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode limited reply %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusTooManyRequests || reply.Error.Code != rpcCodeRateLimited || string(reply.ID) != "1" {
+		t.Fatalf("unexpected limited reply %d %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "4" {
+		t.Fatalf("unexpected Retry-After %q", got)
+	}
+
+	rec = call(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode limited notification reply %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusTooManyRequests || string(reply.ID) != "null" {
+		t.Fatalf("unexpected limited notification reply %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.(*Proxy).AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathMetrics, nil))
+	if want := `mcp_proxy_rate_limited_total{reason="client"} 2`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("metrics output missing %q", want)
+	}
+}
+
+func TestProxyRateLimitsRotatedSessionIDs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.Header.Get("X-Test-Init"), "1") {
+			w.Header().Set(headerMCPSessionID, "issued-session")
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.ClientRateLimit = config.RateLimit{Rate: 0.01, Burst: 2}
+	cfg.RateLimitKey = config.RateLimitKeySession
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	call := func(sessionID, remote string, init bool) int {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testCallRequest))
+		req.RemoteAddr = remote
+		if sessionID != "" {
+			req.Header.Set(headerMCPSessionID, sessionID)
+		}
+		if init {
+			req.Header.Set("X-Test-Init", "1")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A fresh made-up id on every request is charged to the remote IP.
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if got := call(fmt.Sprintf("made-up-%d", i), "192.0.2.1:1000", false); got != want {
+			t.Fatalf("rotated id %d answered %d, want %d", i, got, want)
+		}
+	}
+
+	// A session the upstream issued gets its own bucket.
+	if got := call("", "192.0.2.2:1000", true); got != http.StatusOK {
+		t.Fatalf("initialize answered %d", got)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if got := call("issued-session", "192.0.2.3:1000", false); got != want {
+			t.Fatalf("issued session request %d answered %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestProxyCapsConcurrentUpstreamRequests(t *testing.T) {
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.MaxConcurrentUpstream = 1
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	done := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testCallRequest)))
+		done <- rec.Code
+	}()
+	waitUntil(t, 2*time.Second, func() bool { return len(p.inflight) == 1 })
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(testCallRequest)))
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "in flight") {
+		t.Fatalf("unexpected response over the cap %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	close(unblock)
+	if code := <-done; code != http.StatusNoContent {
+		t.Fatalf("in-flight request failed with %d", code)
+	}
+	if len(p.inflight) != 0 {
+		t.Fatal("slot was not released")
+	}
+}
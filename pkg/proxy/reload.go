@@ -0,0 +1,140 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+// errRoutesChanged rejects reloads that add, remove or move routes; the
+// route table and the sessions behind it are fixed for the process lifetime.
+var errRoutesChanged = errors.New("routes or the aggregate endpoint changed; restart the proxy to apply")
+
+// Reload loads a configuration with load and applies it to the running
+// proxy: each upstream's address, credentials, static headers and session
+// value, the inbound tokens, the rate limits and the tool policy. Requests
+// already in flight finish with what they started with, and open event
+// streams and sessions are kept. Other settings, such as the listener, TLS,
+// timeouts and the concurrency cap, still need a restart; changing one of
+// those that shapes authentication fails the reload. A configuration that fails to load or apply is logged and
+// counted, and the running one stays in place.
+func (p *Proxy) Reload(load func() (config.Config, error)) error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	cfg, err := load()
+	if err == nil {
+		err = p.applyConfig(cfg)
+	}
+	if err != nil {
+		p.metrics.ConfigReload(metrics.ReloadFailure)
+		p.logger.Error().Err(err).Msg("configuration reload failed; keeping the running configuration")
+		return err
+	}
+
+	p.metrics.ConfigReload(metrics.ReloadSuccess)
+	for _, warning := range cfg.Warnings {
+		p.logger.Warn().Msg(warning)
+	}
+	p.logger.Info().
+		Str("upstream", cfg.Upstream.String()).
+		Int("routes", len(cfg.Routes)).
+		Bool("tool_policy", p.policy.Load() != nil).
+		Msg("configuration reloaded")
+	return nil
+}
+
+// applyConfig builds every new target and the tool policy first, and only
+// swaps them in once all of them succeeded.
+func (p *Proxy) applyConfig(cfg config.Config) error {
+	if err := p.checkReloadable(cfg); err != nil {
+		return err
+	}
+
+	members := p.upstreams()
+	targets := make([]*upstreamTarget, len(members))
+	for i, u := range members {
+		ucfg := cfg
+		if u.name != "" {
+			idx := slices.IndexFunc(cfg.Routes, func(r config.Route) bool { return r.Name == u.name })
+			ucfg = cfg.Routes[idx].Config
+		}
+		target, err := u.newTarget(ucfg)
+		if err != nil {
+			if u.name != "" {
+				return fmt.Errorf("route %s: %w", u.name, err)
+			}
+			return err
+		}
+		targets[i] = target
+	}
+
+	toolPolicy, err := loadToolPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	inbound, err := newInboundAuth(cfg, p.authServer)
+	if err != nil {
+		return err
+	}
+	// Unchanged limits keep their limiter so reloads do not refill buckets.
+	limiter := newRateLimiter(cfg)
+	if current := p.limiter.Load(); limiter.sameLimits(current) {
+		limiter = current
+	}
+
+	for i, u := range members {
+		u.target.Store(targets[i])
+	}
+	p.policy.Store(toolPolicy)
+	p.inbound.Store(inbound)
+	p.limiter.Store(limiter)
+	return nil
+}
+
+// checkReloadable reports whether cfg keeps the route table (the same routes
+// at the same prefixes, and the same aggregate endpoint and name prefixes)
+// and the settings the listener and authorization server were built from.
+func (p *Proxy) checkReloadable(cfg config.Config) error {
+	for _, setting := range []struct {
+		name    string
+		changed bool
+	}{
+		{name: "MCP_TLS_CLIENT_CA_FILE", changed: cfg.TLSClientCAFile != p.cfg.TLSClientCAFile},
+		{name: "MCP_AUTH_SERVER_ENABLED", changed: cfg.AuthServerEnabled != p.cfg.AuthServerEnabled},
+		{name: "MCP_AUTH_SERVER_ISSUER", changed: cfg.AuthServerIssuer != p.cfg.AuthServerIssuer},
+		{name: "MCP_AUTH_SERVER_TOKEN_TTL", changed: cfg.AuthServerTokenTTL != p.cfg.AuthServerTokenTTL},
+		{name: "MCP_AUTH_SERVER_REDIRECT_SCHEMES", changed: !slices.Equal(cfg.AuthServerRedirectSchemes, p.cfg.AuthServerRedirectSchemes)},
+	} {
+		if setting.changed {
+			return fmt.Errorf("%s changed; restart the proxy to apply", setting.name)
+		}
+	}
+
+	if len(cfg.Routes) != len(p.routes) || cfg.AggregatePath != p.cfg.AggregatePath || cfg.AggregatePrefix != p.cfg.AggregatePrefix {
+		return errRoutesChanged
+	}
+	for _, u := range p.routes {
+		idx := slices.IndexFunc(cfg.Routes, func(r config.Route) bool { return r.Name == u.name })
+		if idx < 0 {
+			return errRoutesChanged
+		}
+		route := cfg.Routes[idx]
+		if route.Prefix != u.prefix || route.Rewrite != u.rewrite || route.Config.AggregatePrefix != u.cfg.AggregatePrefix {
+			return errRoutesChanged
+		}
+	}
+	return nil
+}
+
+// Reload applies a new configuration to the bridge's proxy; see
+// Proxy.Reload.
+func (b *StdioBridge) Reload(load func() (config.Config, error)) error {
+	return b.proxy.Reload(load)
+}
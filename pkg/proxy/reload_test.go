@@ -0,0 +1,243 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// headerRecorder answers every request with an empty JSON-RPC result and
+// keeps the headers of the last one.
+type headerRecorder struct {
+	mu   sync.Mutex
+	last http.Header
+}
+
+func (h *headerRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.last = r.Header.Clone()
+	h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+}
+
+// header returns a header of the last request, or "" when none arrived.
+func (h *headerRecorder) header(name string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last.Get(name)
+}
+
+func TestProxyReload(t *testing.T) {
+	oldUpstream, newUpstream := &headerRecorder{}, &headerRecorder{}
+	oldServer := httptest.NewServer(oldUpstream)
+	defer oldServer.Close()
+	newServer := httptest.NewServer(newUpstream)
+	defer newServer.Close()
+
+	cfg := newStreamTestConfig(t, oldServer.URL)
+	cfg.UpstreamHeaders = map[string]string{"X-Tenant": "acme"}
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	// A request that started before the reload keeps its target.
+	const body = `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"delete_repo"}}`
+	inflight, _ := p.withUpstream(httptest.NewRequest(http.MethodPost, "http://proxy/mcp", nil))
+
+	reloaded := cfg
+	reloaded.Upstream, _ = url.Parse(newServer.URL)
+	reloaded.APIKey = "rotated-key"
+	reloaded.UpstreamHeaders = map[string]string{"X-Tenant": "globex"}
+	reloaded.ToolPolicy = `{"deny":["delete_*"]}`
+	if err := p.Reload(func() (config.Config, error) { return reloaded, nil }); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	pinned, err := p.newUpstreamRequest(inflight, []byte(body))
+	if err != nil {
+		t.Fatalf("build in-flight request: %v", err)
+	}
+	if pinned.URL.Host != strings.TrimPrefix(oldServer.URL, "http://") || pinned.Header.Get(auth.HeaderAPIKey) != "key-id" || pinned.Header.Get("X-Tenant") != "acme" {
+		t.Fatalf("in-flight request moved to the new config: %s %v", pinned.URL, pinned.Header)
+	}
+
+	call := func(payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(payload)))
+		return rec
+	}
+	if rec := call(`{"jsonrpc":"2.0","id":1,"method":"ping"}`); rec.Code != http.StatusOK {
+		t.Fatalf("request after reload answered %d", rec.Code)
+	}
+	if got := newUpstream.header(auth.HeaderAPIKey); got != "rotated-key" || newUpstream.header("X-Tenant") != "globex" {
+		t.Fatalf("new upstream got key %q and tenant %q", got, newUpstream.header("X-Tenant"))
+	}
+	if rec := call(body); !strings.Contains(rec.Body.String(), "not permitted") {
+		t.Fatalf("reloaded policy not enforced: %s", rec.Body.String())
+	}
+
+	// Failed reloads keep the running configuration.
+	failures := []struct {
+		name string
+		load func() (config.Config, error)
+	}{
+		{name: "load error", load: func() (config.Config, error) { return config.Config{}, errors.New("bad file") }},
+		{name: "route added", load: func() (config.Config, error) {
+			next := reloaded
+			next.Routes = []config.Route{{Name: "gh", Prefix: "/gh", Config: reloaded}}
+			return next, nil
+		}},
+		{name: "bad policy", load: func() (config.Config, error) {
+			next := reloaded
+			next.APIKey = "never-applied"
+			next.ToolPolicy = `{"allow":`
+			return next, nil
+		}},
+	}
+	for _, tc := range failures {
+		if err := p.Reload(tc.load); err == nil {
+			t.Fatalf("%s: reload succeeded", tc.name)
+		}
+	}
+	if rec := call(`{"jsonrpc":"2.0","id":1,"method":"ping"}`); rec.Code != http.StatusOK || newUpstream.header(auth.HeaderAPIKey) != "rotated-key" {
+		t.Fatalf("failed reload changed the running config: %d %q", rec.Code, newUpstream.header(auth.HeaderAPIKey))
+	}
+
+	rec := httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://admin"+PathMetrics, nil))
+	for _, want := range []string{
+		`mcp_proxy_config_reloads_total{result="success"} 1`,
+		`mcp_proxy_config_reloads_total{result="failure"} 3`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestProxyReloadInboundAuthAndLimits(t *testing.T) {
+	upstream := httptest.NewServer(&headerRecorder{})
+	defer upstream.Close()
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("alice alice-token\n"), 0o600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.InboundToken = "old-token"
+	cfg.InboundTokensFile = tokensFile
+	cfg.ClientRateLimit = config.RateLimit{Rate: 1, Burst: 5}
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	limiter := p.limiter.Load()
+
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if err := os.WriteFile(tokensFile, []byte("bob bob-token\n"), 0o600); err != nil {
+		t.Fatalf("rewrite tokens file: %v", err)
+	}
+	reloaded := cfg
+	reloaded.InboundToken = "new-token"
+	if err := p.Reload(func() (config.Config, error) { return reloaded, nil }); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	for token, want := range map[string]int{
+		"old-token":   http.StatusUnauthorized,
+		"alice-token": http.StatusUnauthorized,
+		"new-token":   http.StatusOK,
+		"bob-token":   http.StatusOK,
+	} {
+		if got := status(token); got != want {
+			t.Errorf("token %s answered %d after reload, want %d", token, got, want)
+		}
+	}
+	if p.limiter.Load() != limiter {
+		t.Error("reload with unchanged limits replaced the limiter")
+	}
+
+	reloaded.ClientRateLimit = config.RateLimit{Rate: 1, Burst: 1}
+	if err := p.Reload(func() (config.Config, error) { return reloaded, nil }); err != nil {
+		t.Fatalf("reload limits: %v", err)
+	}
+	if got := p.limiter.Load(); got == limiter || got.client != reloaded.ClientRateLimit {
+		t.Errorf("changed limits not applied: %+v", got.client)
+	}
+
+	next := reloaded
+	next.InboundToken = "never-applied"
+	next.AuthServerEnabled = true
+	if err := p.Reload(func() (config.Config, error) { return next, nil }); err == nil || !strings.Contains(err.Error(), "MCP_AUTH_SERVER_ENABLED") {
+		t.Fatalf("enabling the authorization server on reload: %v", err)
+	}
+	if status("never-applied") != http.StatusUnauthorized {
+		t.Fatal("refused reload changed the inbound token")
+	}
+}
+
+func TestProxyReloadRotatesInboundTokenReference(t *testing.T) {
+	upstream := httptest.NewServer(&headerRecorder{})
+	defer upstream.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "inbound-token")
+	if err := os.WriteFile(secretFile, []byte("first-token\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("MCP_UPSTREAM_URL", upstream.URL)
+	t.Setenv("MCP_API_KEY", "key-id")
+	t.Setenv("MCP_API_SECRET", "secret-value")
+	t.Setenv("MCP_INBOUND_TOKEN", "file://"+secretFile)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	if err := os.WriteFile(secretFile, []byte("second-token\n"), 0o600); err != nil {
+		t.Fatalf("rotate secret: %v", err)
+	}
+	next, err := config.Load()
+	if err != nil {
+		t.Fatalf("load rotated: %v", err)
+	}
+	if next.SecretsDigest == cfg.SecretsDigest {
+		t.Fatal("rotation did not change the secrets digest")
+	}
+	if err := p.Reload(func() (config.Config, error) { return next, nil }); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	for token, want := range map[string]int{"first-token": http.StatusUnauthorized, "second-token": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("token %s answered %d after rotation, want %d", token, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"strconv"
+	"sync"
+)
+
+// headerLastEventID is sent by SSE clients when they reconnect to resume a
+// stream after the last event they received.
+const headerLastEventID = "Last-Event-ID"
+
+// replayBuffer keeps the most recent events relayed to a session so a client
+// reconnecting with Last-Event-ID can catch up on what it missed. Each event
+// remembers the stream it was sent on, since a client resumes one stream and
+// must not receive events that went out on its other streams.
+type replayBuffer struct {
+	// size bounds the number of retained events.
+	size int
+
+	mu sync.Mutex
+	// next numbers events that arrive from the upstream without an id.
+	next uint64
+	// streams numbers the streams recorded so far.
+	streams uint64
+	// events holds the retained events, oldest first.
+	events []replayedEvent
+}
+
+// replayedEvent is a retained event and the stream it was sent on.
+type replayedEvent struct {
+	stream uint64
+	evt    *sseEvent
+}
+
+// replayStream records the events of one stream into its session's buffer.
+type replayStream struct {
+	buf *replayBuffer
+	id  uint64
+}
+
+// newReplayBuffer returns a buffer retaining up to size events.
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{size: size}
+}
+
+// newStream starts recording a new stream, such as a POST response or a GET
+// stream.
+func (b *replayBuffer) newStream() *replayStream {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.streams++
+	return &replayStream{buf: b, id: b.streams}
+}
+
+// record retains evt, assigning it an id when the upstream sent none so the
+// client can refer back to it. Ids are unique across the session's streams.
+// Comment-only frames are neither numbered nor retained.
+func (s *replayStream) record(evt *sseEvent) {
+	if evt.Data == "" && evt.Event == "" {
+		return
+	}
+
+	b := s.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if evt.ID == "" {
+		b.next++
+		evt.ID = strconv.FormatUint(b.next, 10)
+		evt.Raw = append([]byte("id: "+evt.ID+"\n"), evt.Raw...)
+	}
+
+	b.events = append(b.events, replayedEvent{stream: s.id, evt: evt})
+	if len(b.events) > b.size {
+		// Drop the oldest events; copy so the backing array does not grow
+		// without bound.
+		b.events = append([]replayedEvent(nil), b.events[len(b.events)-b.size:]...)
+	}
+}
+
+// since returns the raw frames recorded after the event with the given id on
+// the same stream. ok is false when the id is no longer (or never was) in
+// the buffer.
+func (b *replayBuffer) since(id string) (frames [][]byte, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].evt.ID != id {
+			continue
+		}
+		for _, later := range b.events[i+1:] {
+			if later.stream == b.events[i].stream {
+				frames = append(frames, later.evt.Raw)
+			}
+		}
+		return frames, true
+	}
+	return nil, false
+}
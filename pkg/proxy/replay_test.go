@@ -0,0 +1,178 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplayBufferKeepsMostRecentEvents(t *testing.T) {
+	buf := newReplayBuffer(2)
+	stream := buf.newStream()
+	for _, data := range []string{"a", "b", "c"} {
+		stream.record(&sseEvent{Data: data, Raw: []byte("data: " + data + "\n\n")})
+	}
+	stream.record(&sseEvent{Raw: []byte(":keepalive\n\n")})
+
+	if _, ok := buf.since("1"); ok {
+		t.Fatal("expected evicted event to be unknown")
+	}
+
+	frames, ok := buf.since("2")
+	if !ok {
+		t.Fatal("expected event 2 to be buffered")
+	}
+	if len(frames) != 1 || string(frames[0]) != "id: 3\ndata: c\n\n" {
+		t.Fatalf("unexpected replay: %q", frames)
+	}
+
+	frames, ok = buf.since("3")
+	if !ok || len(frames) != 0 {
+		t.Fatalf("expected nothing to replay after latest event, got %q", frames)
+	}
+}
+
+func TestReplayBufferKeepsStreamsApart(t *testing.T) {
+	buf := newReplayBuffer(10)
+	get, post := buf.newStream(), buf.newStream()
+	get.record(&sseEvent{Data: "g1", Raw: []byte("data: g1\n\n")})
+	post.record(&sseEvent{Data: "p1", Raw: []byte("data: p1\n\n")})
+	get.record(&sseEvent{Data: "g2", Raw: []byte("data: g2\n\n")})
+	post.record(&sseEvent{Data: "p2", Raw: []byte("data: p2\n\n")})
+
+	frames, ok := buf.since("1")
+	if !ok || len(frames) != 1 || string(frames[0]) != "id: 3\ndata: g2\n\n" {
+		t.Fatalf("resuming the GET stream replayed %q", frames)
+	}
+	frames, ok = buf.since("2")
+	if !ok || len(frames) != 1 || string(frames[0]) != "id: 4\ndata: p2\n\n" {
+		t.Fatalf("resuming the POST stream replayed %q", frames)
+	}
+}
+
+func TestProxyReplaysMissedEventsOnReconnect(t *testing.T) {
+	var calls atomic.Int32
+	var resumedUpstream atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if calls.Add(1) == 1 {
+			fmt.Fprint(w, "data: {\"n\":1}\n\ndata: {\"n\":2}\n\n")
+			return
+		}
+		resumedUpstream.Store(r.Header.Get(headerLastEventID) != "")
+		fmt.Fprint(w, "data: {\"n\":3}\n\n")
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SSEReplayBuffer = 10
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	stream := func(lastEventID string) []*sseEvent {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil)
+		req.Header.Set(headerMCPSessionID, "session-1")
+		if lastEventID != "" {
+			req.Header.Set(headerLastEventID, lastEventID)
+		}
+		rec := newFlushRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var events []*sseEvent
+		reader := bufio.NewReader(strings.NewReader(rec.String()))
+		for {
+			evt, err := readSSEEvent(reader)
+			if err != nil {
+				return events
+			}
+			events = append(events, evt)
+		}
+	}
+
+	first := stream("")
+	if len(first) != 2 || first[0].ID != "1" || first[1].ID != "2" {
+		t.Fatalf("expected proxy-assigned ids on first stream, got %+v", first)
+	}
+
+	// The client saw only the first event before its connection dropped.
+	resumed := stream(first[0].ID)
+	if len(resumed) != 2 {
+		t.Fatalf("expected replayed and live events, got %+v", resumed)
+	}
+	if resumed[0].ID != "2" || resumed[0].Data != `{"n":2}` {
+		t.Fatalf("unexpected replayed event: %+v", resumed[0])
+	}
+	if resumed[1].ID != "3" || resumed[1].Data != `{"n":3}` {
+		t.Fatalf("unexpected live event: %+v", resumed[1])
+	}
+	if resumedUpstream.Load() {
+		t.Fatal("Last-Event-ID should not reach the upstream when replayed locally")
+	}
+}
+
+func TestProxyResumeSkipsPostStreamEvents(t *testing.T) {
+	var gets atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case r.Method == http.MethodPost:
+			fmt.Fprint(w, "data: {\"progress\":1}\n\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n")
+		case gets.Add(1) == 1:
+			fmt.Fprint(w, "data: {\"n\":1}\n\n")
+		default:
+			fmt.Fprint(w, "data: {\"n\":2}\n\n")
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SSEReplayBuffer = 10
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	send := func(req *http.Request) []*sseEvent {
+		req.Header.Set(headerMCPSessionID, "session-1")
+		rec := newFlushRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var events []*sseEvent
+		reader := bufio.NewReader(strings.NewReader(rec.String()))
+		for {
+			evt, err := readSSEEvent(reader)
+			if err != nil {
+				return events
+			}
+			events = append(events, evt)
+		}
+	}
+
+	first := send(httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil))
+	if len(first) != 1 {
+		t.Fatalf("expected one event on the GET stream, got %+v", first)
+	}
+	post := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`))
+	post.Header.Set("Accept", "application/json, text/event-stream")
+	if events := send(post); len(events) != 2 {
+		t.Fatalf("expected the POST stream to be relayed, got %+v", events)
+	}
+
+	resume := httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil)
+	resume.Header.Set(headerLastEventID, first[0].ID)
+	resumed := send(resume)
+	if len(resumed) != 1 || resumed[0].Data != `{"n":2}` {
+		t.Fatalf("resuming the GET stream should only carry live events, got %+v", resumed)
+	}
+}
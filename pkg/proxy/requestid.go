@@ -0,0 +1,72 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// headerRequestID correlates a request across the client, the proxy logs and
+// the upstream gateway.
+const headerRequestID = "X-Request-Id"
+
+// maxRequestIDLength bounds client-supplied request ids so they cannot bloat
+// logs or upstream headers.
+const maxRequestIDLength = 128
+
+// requestIDKey carries the request id in the request context.
+type requestIDKey struct{}
+
+// withRequestID stores the client's X-Request-Id in the request context, or a
+// newly generated one when the client sent none or an unusable value.
+func withRequestID(r *http.Request) *http.Request {
+	id := r.Header.Get(headerRequestID)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// requestID returns the id stored by withRequestID, or "" when there is none.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts non-empty ids of visible ASCII characters up to
+// maxRequestIDLength.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex id.
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(fmt.Sprintf("generate request id: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// writeLocalError replies with a plain-text error naming the request id so
+// clients can quote it when reporting problems.
+func writeLocalError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if id := requestID(r.Context()); id != "" {
+		message = fmt.Sprintf("%s (request id %s)", message, id)
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,108 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+)
+
+func TestProxyPropagatesRequestID(t *testing.T) {
+	forwarded := make(chan http.Header, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Clone()
+		w.Header().Set(headerRequestID, "upstream-echo")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SignatureVersion = auth.SignatureV2
+	cfg.SignedHeaders = []string{headerRequestID}
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		inbound  string
+		expected string
+	}{
+		{name: "client supplied", inbound: "client-req-42", expected: "client-req-42"},
+		{name: "generated when missing"},
+		{name: "replaced when invalid", inbound: "has spaces in it"},
+		{name: "replaced when too long", inbound: strings.Repeat("x", maxRequestIDLength+1)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{}`))
+			if tc.inbound != "" {
+				req.Header.Set(headerRequestID, tc.inbound)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Values(headerRequestID)
+			if len(echoed) != 1 || !validRequestID(echoed[0]) {
+				t.Fatalf("unexpected echoed request ids %q", echoed)
+			}
+			if tc.expected != "" && echoed[0] != tc.expected {
+				t.Fatalf("echoed %q, want %q", echoed[0], tc.expected)
+			}
+			if tc.inbound != "" && tc.expected == "" && echoed[0] == tc.inbound {
+				t.Fatal("invalid request id was not replaced")
+			}
+
+			header := <-forwarded
+			if got := header.Get(headerRequestID); got != echoed[0] {
+				t.Fatalf("upstream saw %q, client saw %q", got, echoed[0])
+			}
+			if got := header.Get(auth.HeaderSignedHeaders); !strings.Contains(got, "x-request-id") {
+				t.Fatalf("request id not covered by the signature: %q", got)
+			}
+		})
+	}
+}
+
+func TestLocalErrorsIncludeRequestID(t *testing.T) {
+	cfg := newStreamTestConfig(t, "http://upstream.invalid")
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	handler.(*Proxy).client.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{}`))
+	req.Header.Set(headerRequestID, "req-502")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "request id req-502") {
+		t.Fatalf("unexpected error response %d %q", rec.Code, rec.Body.String())
+	}
+
+	cfg.InboundToken = "client-token"
+	handler, err = New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{}`))
+	req.Header.Set(headerRequestID, "req-401")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "request id req-401") ||
+		rec.Header().Get(headerRequestID) != "req-401" {
+		t.Fatalf("unexpected challenge %d %q", rec.Code, rec.Body.String())
+	}
+}
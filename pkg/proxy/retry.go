@@ -0,0 +1,199 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// defaultRetrySafeMethods are the JSON-RPC methods retried when the operator
+// has not listed any: they only read state, so repeating them is harmless.
+var defaultRetrySafeMethods = []string{
+	"ping",
+	"tools/list",
+	"resources/list",
+	"resources/read",
+	"resources/templates/list",
+	"prompts/list",
+	"prompts/get",
+}
+
+// retryBudgetReserve is the number of retries available before any request
+// has deposited into the budget, and the most the budget can accumulate.
+const retryBudgetReserve = 10
+
+// retryPolicy decides whether and when a failed upstream attempt is repeated.
+type retryPolicy struct {
+	// maxAttempts counts the first attempt; it is always at least 2.
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	// allMethods retries every request instead of only safeMethods.
+	allMethods  bool
+	safeMethods map[string]struct{}
+	// budget bounds retries to a share of traffic; nil leaves them unbounded.
+	budget *retryBudget
+}
+
+// newRetryPolicy returns the policy configured in cfg, or nil when retries
+// are disabled.
+func newRetryPolicy(cfg config.Config) *retryPolicy {
+	if cfg.RetryMaxAttempts <= 1 {
+		return nil
+	}
+
+	methods := cfg.RetrySafeMethods
+	if len(methods) == 0 {
+		methods = defaultRetrySafeMethods
+	}
+	safe := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		safe[method] = struct{}{}
+	}
+
+	policy := &retryPolicy{
+		maxAttempts: cfg.RetryMaxAttempts,
+		baseDelay:   cfg.RetryBaseDelay,
+		maxDelay:    cfg.RetryMaxDelay,
+		allMethods:  cfg.RetryAllMethods,
+		safeMethods: safe,
+	}
+	if cfg.RetryBudget > 0 {
+		policy.budget = &retryBudget{ratio: cfg.RetryBudget, tokens: retryBudgetReserve}
+	}
+	return policy
+}
+
+// allows reports whether the request may be sent more than once: either the
+// operator opted into retrying everything, or it is a POST whose JSON-RPC
+// messages all use safe methods.
+func (rp *retryPolicy) allows(r *http.Request, body []byte) bool {
+	if rp.allMethods {
+		return true
+	}
+	if r.Method != http.MethodPost {
+		return false
+	}
+	msgs, _ := decodeRPC(body)
+	if len(msgs) == 0 {
+		return false
+	}
+	for i := range msgs {
+		if _, ok := rp.safeMethods[msgs[i].Method]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// backoff returns how long to wait before the given retry (1 for the first
+// retry), or false when the attempt should not be retried. Retry-After on
+// 429 and 503 answers takes precedence over the exponential schedule.
+func (rp *retryPolicy) backoff(retry int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		return rp.jittered(retry), isRetryableError(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	case http.StatusTooManyRequests:
+		// Without Retry-After the upstream has not said when capacity returns.
+		if resp.Header.Get("Retry-After") == "" {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		wait, ok := parseRetryAfter(value, time.Now())
+		if !ok || wait > rp.maxDelay {
+			// Waiting longer than the configured ceiling would stall the client.
+			return 0, false
+		}
+		return wait, true
+	}
+	return rp.jittered(retry), true
+}
+
+// jittered returns a random delay between zero and the capped exponential
+// backoff for the given retry ("full jitter").
+func (rp *retryPolicy) jittered(retry int) time.Duration {
+	ceiling := rp.maxDelay
+	if shift := retry - 1; shift < 32 {
+		if exp := rp.baseDelay << shift; exp > 0 && exp < ceiling {
+			ceiling = exp
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// isRetryableError reports whether err is a transport failure worth another
+// attempt. Timeouts are not retried: the attempt already used the full
+// request timeout.
+func isRetryableError(err error) bool {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter interprets a Retry-After value given in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := at.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// retryBudget limits retries to a share of retryable requests so a failing
+// upstream is not hit with a multiple of its normal load. Every request
+// deposits ratio tokens and every retry withdraws one.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+// deposit credits the budget for one retryable request.
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, retryBudgetReserve)
+}
+
+// withdraw takes one retry from the budget, reporting false when it is spent.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
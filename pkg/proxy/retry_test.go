@@ -0,0 +1,170 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+)
+
+const (
+	testListRequest = `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	testCallRequest = `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`
+)
+
+// failingUpstream answers the first len(failures) attempts with the given
+// outcomes and succeeds afterwards. A zero status fails the attempt at the
+// transport level.
+type failingUpstream struct {
+	mu         sync.Mutex
+	failures   []*http.Response
+	timestamps []string
+}
+
+func (u *failingUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.timestamps = append(u.timestamps, req.Header.Get(auth.HeaderTimestamp))
+	if attempt := len(u.timestamps); attempt <= len(u.failures) {
+		resp := u.failures[attempt-1]
+		if resp.StatusCode == 0 {
+			return nil, errors.New("connection reset by peer")
+		}
+		resp.Body = io.NopCloser(strings.NewReader("failure"))
+		return resp, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{}}`)),
+	}, nil
+}
+
+func (u *failingUpstream) attempts() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.timestamps)
+}
+
+func failWith(status int, header ...string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+	for i := 0; i+1 < len(header); i += 2 {
+		resp.Header.Set(header[i], header[i+1])
+	}
+	return resp
+}
+
+func TestProxyRetriesTransientUpstreamFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		allMethod bool
+		failures  []*http.Response
+		attempts  int
+		status    int
+	}{
+		{name: "bad gateway then success", body: testListRequest, failures: []*http.Response{failWith(502), failWith(503)}, attempts: 3, status: http.StatusOK},
+		{name: "network error", body: testListRequest, failures: []*http.Response{failWith(0)}, attempts: 2, status: http.StatusOK},
+		{name: "attempts exhausted", body: testListRequest, failures: []*http.Response{failWith(504), failWith(504), failWith(504)}, attempts: 3, status: http.StatusGatewayTimeout},
+		{name: "unsafe method", body: testCallRequest, failures: []*http.Response{failWith(503)}, attempts: 1, status: http.StatusServiceUnavailable},
+		{name: "all methods opted in", body: testCallRequest, allMethod: true, failures: []*http.Response{failWith(503)}, attempts: 2, status: http.StatusOK},
+		{name: "internal error", body: testListRequest, failures: []*http.Response{failWith(500)}, attempts: 1, status: http.StatusInternalServerError},
+		{name: "throttled without retry-after", body: testListRequest, failures: []*http.Response{failWith(429)}, attempts: 1, status: http.StatusTooManyRequests},
+		{name: "throttled with retry-after", body: testListRequest, failures: []*http.Response{failWith(429, "Retry-After", "0")}, attempts: 2, status: http.StatusOK},
+		{name: "retry-after beyond max delay", body: testListRequest, failures: []*http.Response{failWith(503, "Retry-After", "120")}, attempts: 1, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStreamTestConfig(t, "http://upstream.invalid")
+			cfg.RetryMaxAttempts = 3
+			cfg.RetryBaseDelay = time.Millisecond
+			cfg.RetryMaxDelay = 10 * time.Millisecond
+			cfg.RetryAllMethods = tc.allMethod
+			handler, err := New(cfg)
+			if err != nil {
+				t.Fatalf("create proxy: %v", err)
+			}
+			p := handler.(*Proxy)
+
+			upstream := &failingUpstream{failures: tc.failures}
+			p.client.Transport = upstream
+			clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			p.current().authenticator.(*auth.Signer).Now = func() time.Time {
+				clock = clock.Add(time.Second)
+				return clock
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.status || upstream.attempts() != tc.attempts {
+				t.Fatalf("got status %d after %d attempts, want %d after %d",
+					rec.Code, upstream.attempts(), tc.status, tc.attempts)
+			}
+			seen := make(map[string]bool)
+			for _, ts := range upstream.timestamps {
+				if seen[ts] {
+					t.Fatalf("attempt reused signature timestamp %s", ts)
+				}
+				seen[ts] = true
+			}
+		})
+	}
+}
+
+func TestRetryBudgetLimitsRetries(t *testing.T) {
+	budget := &retryBudget{ratio: 0.5, tokens: 1}
+	if !budget.withdraw() {
+		t.Fatal("reserve should allow a retry")
+	}
+	if budget.withdraw() {
+		t.Fatal("empty budget allowed a retry")
+	}
+	budget.deposit()
+	if budget.withdraw() {
+		t.Fatal("half a token allowed a retry")
+	}
+	budget.deposit()
+	if !budget.withdraw() {
+		t.Fatal("two deposits should fund a retry")
+	}
+
+	for range 100 {
+		budget.deposit()
+	}
+	if budget.tokens != retryBudgetReserve {
+		t.Fatalf("budget grew past its reserve: %v", budget.tokens)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{value: "3", wait: 3 * time.Second, ok: true},
+		{value: "Wed, 01 Jan 2025 00:00:05 GMT", wait: 5 * time.Second, ok: true},
+		{value: "Tue, 31 Dec 2024 23:59:00 GMT", wait: 0, ok: true},
+		{value: "-1"},
+		{value: "soon"},
+	}
+	for _, tc := range tests {
+		wait, ok := parseRetryAfter(tc.value, now)
+		if wait != tc.wait || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, wait, ok, tc.wait, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// upstreamKey stores the upstream selected for a request in its context.
+type upstreamKey struct{}
+
+// upstream is one server the proxy forwards to: the default one named by
+// MCP_UPSTREAM_URL or a route mounted under a local path prefix. Each has its
+// own credentials, HTTP client, circuit breaker and session table.
+type upstream struct {
+	// name identifies a route in logs; it is empty for the default upstream.
+	name string
+	// prefix is the local path prefix of a route and rewrite what replaces
+	// it upstream; both are empty for the default upstream.
+	prefix  string
+	rewrite string
+	// cfg holds the upstream settings as loaded at startup. Timeouts, TLS,
+	// SSE mode and session handling are read from it; the address,
+	// credentials and headers come from target, which a reload replaces.
+	cfg config.Config
+	// target is the current *upstreamTarget.
+	target atomic.Pointer[upstreamTarget]
+	// client performs outbound HTTP requests with tuned transport settings.
+	client *http.Client
+	// breaker fails requests fast while the upstream keeps failing; nil
+	// disables it.
+	breaker *circuitBreaker
+	// sessions tracks Mcp-Session-Id values issued by the upstream.
+	sessions *sessionStore
+}
+
+// upstreamTarget is the part of an upstream a configuration reload swaps:
+// where requests go and how they are signed. It is never modified once
+// stored, so a request can keep using the one it started with.
+type upstreamTarget struct {
+	// baseURL is the parsed upstream address used to resolve inbound paths.
+	baseURL *url.URL
+	// authenticator injects upstream credentials (HMAC signature by default).
+	authenticator auth.RequestAuthenticator
+	// headers are static headers added to every request, followed by the
+	// session header when sessionValue is set.
+	headers       map[string]string
+	sessionHeader string
+	sessionValue  string
+}
+
+// targetKey pins the target a request started with, so a reload does not
+// change its address or credentials halfway through, for example between
+// retries.
+type targetKey struct{}
+
+// pinnedTarget is the value stored under targetKey; it only applies while
+// the request is still bound to the same upstream.
+type pinnedTarget struct {
+	upstream *upstream
+	target   *upstreamTarget
+}
+
+// newUpstream builds the client, authenticator, circuit breaker and session
+// table for route; the default upstream is a route without a name.
+func (p *Proxy) newUpstream(route config.Route) (*upstream, error) {
+	cfg := route.Config
+
+	// Build a transport that honours system proxies and keeps connections warm.
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: cfg.InsecureSkipVerify, // nolint:gosec -- opt-in for development scenarios
+		},
+	}
+
+	u := &upstream{
+		name:    route.Name,
+		prefix:  route.Prefix,
+		rewrite: route.Rewrite,
+		cfg:     cfg,
+		// Deadlines are enforced per request by forwardRequest so event
+		// streams are not cut off by RequestTimeout.
+		client: &http.Client{Transport: transport},
+	}
+	target, err := u.newTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+	u.target.Store(target)
+
+	u.sessions = newSessionStore(cfg.SessionTranslate, cfg.SessionGracePeriod, cfg.SSEReplayBuffer, func(s *mcpSession) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.GracefulShutdownTimeout)
+		defer cancel()
+		p.endSession(context.WithValue(ctx, upstreamKey{}, u), s)
+	})
+
+	u.breaker = newCircuitBreaker(cfg, func(from, to breakerState) {
+		// The state gauge has no route label; it follows the default upstream.
+		if u.name == "" {
+			p.metrics.SetCircuitState(to.String())
+		}
+		p.logger.Warn().
+			Str("route", u.name).
+			Str("from", from.String()).
+			Str("to", to.String()).
+			Msg("upstream circuit breaker changed state")
+	})
+	return u, nil
+}
+
+// newTarget builds the address, authenticator and headers cfg describes.
+// Token endpoints are reached through the upstream's transport.
+func (u *upstream) newTarget(cfg config.Config) (*upstreamTarget, error) {
+	authenticator, err := newAuthenticator(cfg, &http.Client{
+		Timeout:   u.cfg.RequestTimeout,
+		Transport: u.client.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamTarget{
+		baseURL:       cloneURL(cfg.Upstream),
+		authenticator: authenticator,
+		headers:       cfg.UpstreamHeaders,
+		sessionHeader: cfg.SessionHeader,
+		sessionValue:  cfg.SessionValue,
+	}, nil
+}
+
+// current returns the target new requests are sent to.
+func (u *upstream) current() *upstreamTarget {
+	return u.target.Load()
+}
+
+// matches reports whether the local path falls under the route's prefix.
+func (u *upstream) matches(path string) bool {
+	return path == u.prefix || strings.HasPrefix(path, u.prefix+"/")
+}
+
+// upstreamPath maps a local path to the path sent upstream: a route's prefix
+// is stripped, or replaced by its rewrite.
+func (u *upstream) upstreamPath(path string) string {
+	if u.prefix == "" {
+		return path
+	}
+	mapped := u.rewrite + strings.TrimPrefix(path, u.prefix)
+	if !strings.HasPrefix(mapped, "/") {
+		mapped = "/" + mapped
+	}
+	return mapped
+}
+
+// singleJoiningURL resolves the incoming path, mapped for the route, relative
+// to base, the address of the upstream's target.
+func (u *upstream) singleJoiningURL(base, requestURL *url.URL) *url.URL {
+	ref := &url.URL{
+		Path:     u.upstreamPath(requestURL.Path),
+		RawQuery: requestURL.RawQuery,
+		Fragment: requestURL.Fragment,
+	}
+	// Keep the client's escaping when it survives the mapping unchanged.
+	if requestURL.RawPath != "" && (u.prefix == "" || strings.HasPrefix(requestURL.RawPath, u.prefix)) {
+		ref.RawPath = u.upstreamPath(requestURL.RawPath)
+	}
+	return base.ResolveReference(ref)
+}
+
+// routeFor returns the upstream serving the local path: the route with the
+// longest matching prefix, or the default upstream.
+func (p *Proxy) routeFor(path string) *upstream {
+	for _, route := range p.routes {
+		if route.matches(path) {
+			return route
+		}
+	}
+	return p.upstream
+}
+
+// withUpstream selects the upstream for r and records it on the request
+// context for the forwarding path, pinning its current target.
+func (p *Proxy) withUpstream(r *http.Request) (*http.Request, *upstream) {
+	u := p.routeFor(r.URL.Path)
+	ctx := context.WithValue(r.Context(), upstreamKey{}, u)
+	ctx = context.WithValue(ctx, targetKey{}, pinnedTarget{upstream: u, target: u.current()})
+	return r.WithContext(ctx), u
+}
+
+// upstreamFor returns the upstream recorded on ctx, defaulting to the one
+// named by MCP_UPSTREAM_URL.
+func (p *Proxy) upstreamFor(ctx context.Context) *upstream {
+	if u, ok := ctx.Value(upstreamKey{}).(*upstream); ok {
+		return u
+	}
+	return p.upstream
+}
+
+// targetFor returns the target of the upstream recorded on ctx: the one the
+// request pinned, or the current one.
+func (p *Proxy) targetFor(ctx context.Context) *upstreamTarget {
+	u := p.upstreamFor(ctx)
+	if pin, ok := ctx.Value(targetKey{}).(pinnedTarget); ok && pin.upstream == u {
+		return pin.target
+	}
+	return u.current()
+}
+
+// upstreams returns the default upstream followed by every route.
+func (p *Proxy) upstreams() []*upstream {
+	return append([]*upstream{p.upstream}, p.routes...)
+}
@@ -0,0 +1,149 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+func TestUpstreamSingleJoiningURL(t *testing.T) {
+	base, _ := url.Parse("https://upstream.example.com/base")
+	tests := []struct {
+		name    string
+		prefix  string
+		rewrite string
+		path    string
+		query   string
+		want    string
+	}{
+		{name: "default upstream", path: "/mcp", query: "a=1", want: "https://upstream.example.com/mcp?a=1"},
+		{name: "prefix stripped", prefix: "/github", path: "/github/mcp", want: "https://upstream.example.com/mcp"},
+		{name: "prefix only", prefix: "/github", path: "/github", want: "https://upstream.example.com/"},
+		{name: "prefix rewritten", prefix: "/jira/mcp", rewrite: "/v2/mcp", path: "/jira/mcp", want: "https://upstream.example.com/v2/mcp"},
+		{name: "rewritten subpath", prefix: "/jira", rewrite: "/api", path: "/jira/mcp", query: "x=y", want: "https://upstream.example.com/api/mcp?x=y"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &upstream{prefix: tc.prefix, rewrite: tc.rewrite}
+			got := u.singleJoiningURL(base, &url.URL{Path: tc.path, RawQuery: tc.query})
+			if got.String() != tc.want {
+				t.Fatalf("singleJoiningURL(%s) = %s, want %s", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRouteForPicksLongestPrefix(t *testing.T) {
+	cfg := newStreamTestConfig(t, "http://default.invalid")
+	for _, prefix := range []string{"/a", "/a/b"} {
+		cfg.Routes = append(cfg.Routes, config.Route{Name: prefix, Prefix: prefix, Config: cfg})
+	}
+	p, err := newProxy(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	tests := map[string]string{
+		"/a/b/mcp": "/a/b",
+		"/a/bc":    "/a",
+		"/a":       "/a",
+		"/ab/mcp":  "",
+		"/mcp":     "",
+	}
+	for path, want := range tests {
+		if got := p.routeFor(path).prefix; got != want {
+			t.Errorf("routeFor(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestProxyRoutesByPathPrefix(t *testing.T) {
+	defaultHits := make(chan *http.Request, 1)
+	defaultUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defaultHits <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer defaultUpstream.Close()
+
+	routeHits := make(chan *http.Request, 1)
+	routeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routeHits <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer routeUpstream.Close()
+
+	cfg := newStreamTestConfig(t, defaultUpstream.URL)
+	cfg.SessionHeader = "x-session-id"
+	cfg.SessionValue = "default-session"
+	cfg.UpstreamHeaders = map[string]string{"X-Tenant": "acme"}
+	routeCfg := newStreamTestConfig(t, routeUpstream.URL)
+	routeCfg.AuthMode = auth.ModeBearer
+	routeCfg.BearerToken = "github-token"
+	routeCfg.SessionHeader = "x-github-user"
+	routeCfg.SessionValue = "octocat"
+	routeCfg.SSEMode = config.SSEModeLocal
+	cfg.Routes = []config.Route{{Name: "github", Prefix: "/github", Config: routeCfg}}
+
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	post := func(path string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy"+path, strings.NewReader(testListRequest)))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("POST %s answered %d", path, rec.Code)
+		}
+	}
+
+	post("/github/mcp")
+	got := <-routeHits
+	if got.URL.Path != "/mcp" || got.Header.Get("Authorization") != "Bearer github-token" ||
+		got.Header.Get("x-github-user") != "octocat" || got.Header.Get(auth.HeaderSignature) != "" ||
+		got.Header.Get("X-Tenant") != "" {
+		t.Fatalf("route upstream got %s with headers %v", got.URL.Path, got.Header)
+	}
+
+	post("/mcp")
+	got = <-defaultHits
+	if got.URL.Path != "/mcp" || got.Header.Get(auth.HeaderSignature) == "" ||
+		got.Header.Get("x-session-id") != "default-session" || got.Header.Get("Authorization") != "" ||
+		got.Header.Get("X-Tenant") != "acme" {
+		t.Fatalf("default upstream got %s with headers %v", got.URL.Path, got.Header)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy/github/.well-known/oauth-protected-resource", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("route discovery probe answered %d", rec.Code)
+	}
+
+	// The route serves the local heartbeat while the default upstream would
+	// relay its own stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy/github/mcp", nil).WithContext(ctx))
+	if rec.Header().Get("Content-Type") != "text/event-stream" || !strings.HasPrefix(rec.Body.String(), ":ok") {
+		t.Fatalf("route event stream answered %d %q", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case r := <-routeHits:
+		t.Fatalf("local paths reached the route upstream: %s %s", r.Method, r.URL.Path)
+	case r := <-defaultHits:
+		t.Fatalf("route request reached the default upstream: %s %s", r.Method, r.URL.Path)
+	default:
+	}
+}
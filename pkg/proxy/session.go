@@ -0,0 +1,363 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errUnknownSession is returned when a client presents a translated session
+// id the proxy never issued. MCP clients answer 404 by re-initializing.
+var errUnknownSession = &httpError{Status: http.StatusNotFound, Err: errors.New("unknown " + headerMCPSessionID)}
+
+// mcpSession pairs the id a client uses with the id the upstream issued.
+type mcpSession struct {
+	// clientID is the id exposed to the client; it equals upstreamID unless
+	// translation is enabled.
+	clientID string
+	// upstreamID is the id the upstream returned on initialize.
+	upstreamID string
+	// path is the MCP endpoint the session was used with; teardown DELETEs
+	// are sent there.
+	path string
+	// issued is set once the upstream returned the id, as opposed to a
+	// client presenting an id the proxy adopted without translation.
+	issued bool
+	// conns holds the keys of open client connections that used the session.
+	conns map[string]struct{}
+	// reap fires the upstream DELETE once every connection has gone away.
+	reap *time.Timer
+	// replay retains recent stream events for Last-Event-ID resumption; nil
+	// when replay is disabled.
+	replay *replayBuffer
+}
+
+// sessionStore tracks Streamable HTTP sessions so the proxy can translate ids
+// and end sessions upstream when their clients disappear.
+type sessionStore struct {
+	// translate hides upstream ids behind proxy-issued ones.
+	translate bool
+	// grace is how long a session may outlive its last client connection;
+	// zero keeps sessions until shutdown or an explicit DELETE.
+	grace time.Duration
+	// replaySize bounds each session's replay buffer; zero disables replay.
+	replaySize int
+	// end tears a session down upstream; it is called without mu held.
+	end func(s *mcpSession)
+
+	mu         sync.Mutex
+	byClient   map[string]*mcpSession
+	byUpstream map[string]*mcpSession
+	// byConn indexes sessions by the client connections that used them.
+	byConn map[string]map[*mcpSession]struct{}
+}
+
+// newSessionStore returns an empty store. end is invoked for sessions that
+// are reaped after their clients went away.
+func newSessionStore(translate bool, grace time.Duration, replaySize int, end func(s *mcpSession)) *sessionStore {
+	return &sessionStore{
+		translate:  translate,
+		grace:      grace,
+		replaySize: replaySize,
+		end:        end,
+		byClient:   make(map[string]*mcpSession),
+		byUpstream: make(map[string]*mcpSession),
+		byConn:     make(map[string]map[*mcpSession]struct{}),
+	}
+}
+
+// inbound maps the client's session header on r to the upstream id and
+// records the connection it arrived on. The returned request is a shallow
+// clone whenever the header changes.
+func (s *sessionStore) inbound(r *http.Request) (*http.Request, error) {
+	clientID := r.Header.Get(headerMCPSessionID)
+	if clientID == "" {
+		return r, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.byClient[clientID]
+	if !ok {
+		if s.translate {
+			return nil, errUnknownSession
+		}
+		// Without translation the id is opaque to us; adopt it so it can
+		// still be torn down later.
+		session = s.addLocked(clientID, clientID, r.URL.Path)
+	}
+	s.attachLocked(session, r.RemoteAddr)
+
+	if session.upstreamID == clientID {
+		return r, nil
+	}
+	clone := r.Clone(r.Context())
+	clone.Header.Set(headerMCPSessionID, session.upstreamID)
+	return clone, nil
+}
+
+// outbound records sessions issued by the upstream in resp and rewrites the
+// header to the client-facing id. r is the request after inbound mapping.
+func (s *sessionStore) outbound(r *http.Request, resp *http.Response) {
+	sent := r.Header.Get(headerMCPSessionID)
+
+	if sent != "" && (resp.StatusCode == http.StatusNotFound ||
+		(r.Method == http.MethodDelete && resp.StatusCode < http.StatusBadRequest)) {
+		// The session expired upstream or the client ended it explicitly.
+		s.forget(sent)
+	}
+
+	upstreamID := resp.Header.Get(headerMCPSessionID)
+	if upstreamID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.byUpstream[upstreamID]
+	if !ok {
+		clientID := upstreamID
+		if s.translate {
+			clientID = newSessionID()
+		}
+		session = s.addLocked(clientID, upstreamID, r.URL.Path)
+	}
+	session.issued = true
+	s.attachLocked(session, r.RemoteAddr)
+	resp.Header.Set(headerMCPSessionID, session.clientID)
+}
+
+// issued reports whether clientID names a session the upstream issued.
+func (s *sessionStore) issued(clientID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.byClient[clientID]
+	return ok && session.issued
+}
+
+// replay returns the replay buffer of the session the client knows as
+// clientID, or nil when the session is unknown or replay is disabled.
+func (s *sessionStore) replay(clientID string) *replayBuffer {
+	if clientID == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.byClient[clientID]; ok {
+		return session.replay
+	}
+	return nil
+}
+
+// forget drops the session with the given upstream id without ending it.
+func (s *sessionStore) forget(upstreamID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.byUpstream[upstreamID]; ok {
+		s.removeLocked(session)
+	}
+}
+
+// connClosed detaches conn from its sessions and schedules the upstream
+// DELETE for any session left without a connection.
+func (s *sessionStore) connClosed(conn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for session := range s.byConn[conn] {
+		delete(session.conns, conn)
+		if len(session.conns) > 0 || s.grace <= 0 || session.reap != nil {
+			continue
+		}
+		session.reap = time.AfterFunc(s.grace, func() { s.reapSession(session) })
+	}
+	delete(s.byConn, conn)
+}
+
+// reapSession ends session upstream unless a client picked it up again.
+func (s *sessionStore) reapSession(session *mcpSession) {
+	s.mu.Lock()
+	if session.reap == nil || len(session.conns) > 0 || s.byClient[session.clientID] != session {
+		s.mu.Unlock()
+		return
+	}
+	s.removeLocked(session)
+	s.mu.Unlock()
+
+	s.end(session)
+}
+
+// drain removes and returns every tracked session.
+func (s *sessionStore) drain() []*mcpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*mcpSession, 0, len(s.byClient))
+	for _, session := range s.byClient {
+		sessions = append(sessions, session)
+	}
+	for _, session := range sessions {
+		s.removeLocked(session)
+	}
+	return sessions
+}
+
+// addLocked registers a new session; s.mu must be held.
+func (s *sessionStore) addLocked(clientID, upstreamID, path string) *mcpSession {
+	session := &mcpSession{
+		clientID:   clientID,
+		upstreamID: upstreamID,
+		path:       path,
+		conns:      make(map[string]struct{}),
+	}
+	if s.replaySize > 0 {
+		session.replay = newReplayBuffer(s.replaySize)
+	}
+	s.byClient[clientID] = session
+	s.byUpstream[upstreamID] = session
+	return session
+}
+
+// attachLocked binds session to conn and cancels any pending reap; s.mu must
+// be held. Requests without a connection key (stdio) are not tracked.
+func (s *sessionStore) attachLocked(session *mcpSession, conn string) {
+	if session.reap != nil {
+		session.reap.Stop()
+		session.reap = nil
+	}
+	if conn == "" {
+		return
+	}
+	session.conns[conn] = struct{}{}
+	if s.byConn[conn] == nil {
+		s.byConn[conn] = make(map[*mcpSession]struct{})
+	}
+	s.byConn[conn][session] = struct{}{}
+}
+
+// removeLocked unregisters session; s.mu must be held.
+func (s *sessionStore) removeLocked(session *mcpSession) {
+	if session.reap != nil {
+		session.reap.Stop()
+		session.reap = nil
+	}
+	delete(s.byClient, session.clientID)
+	delete(s.byUpstream, session.upstreamID)
+	for conn := range session.conns {
+		delete(s.byConn[conn], session)
+		if len(s.byConn[conn]) == 0 {
+			delete(s.byConn, conn)
+		}
+	}
+}
+
+// newSessionID returns a random client-facing session id.
+func newSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(fmt.Sprintf("generate session id: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// ConnState detaches closed client connections from their MCP sessions so
+// orphaned sessions can be ended upstream. Install it as http.Server.ConnState.
+func (p *Proxy) ConnState(conn net.Conn, state http.ConnState) {
+	if state == http.StateClosed || state == http.StateHijacked {
+		for _, u := range p.upstreams() {
+			u.sessions.connClosed(conn.RemoteAddr().String())
+		}
+	}
+}
+
+// Shutdown ends every tracked MCP session upstream with a DELETE and then
+// flushes buffered trace spans. It returns once both finished or ctx is done.
+func (p *Proxy) Shutdown(ctx context.Context) {
+	p.endSessions(ctx)
+	if err := p.tracing.Shutdown(ctx); err != nil {
+		p.logger.Warn().Err(err).Msg("flush trace spans failed")
+	}
+}
+
+// endSessions sends a DELETE for every tracked session of every upstream,
+// including those behind the aggregated endpoint, in parallel.
+func (p *Proxy) endSessions(ctx context.Context) {
+	var wg sync.WaitGroup
+	if p.aggregator != nil {
+		for clientID, ids := range p.aggregator.drain() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.endAggregateMembers(ctx, clientID, ids)
+			}()
+		}
+	}
+	for _, u := range p.upstreams() {
+		sessionCtx := context.WithValue(ctx, upstreamKey{}, u)
+		for _, session := range u.sessions.drain() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.endSession(sessionCtx, session)
+			}()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.logger.Warn().Err(ctx.Err()).Msg("session teardown did not finish")
+	}
+}
+
+// endSession sends a signed DELETE for session to the upstream selected on
+// ctx.
+func (p *Proxy) endSession(ctx context.Context, session *mcpSession) {
+	event := p.logger.With().
+		Str("method", http.MethodDelete).
+		Str("path", session.path).
+		Str("session", session.clientID).
+		Logger()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, session.path, nil)
+	if err != nil {
+		event.Error().Err(err).Msg("build session teardown request failed")
+		return
+	}
+	req.Header.Set(headerMCPSessionID, session.upstreamID)
+
+	upstreamReq, err := p.newUpstreamRequest(req, nil)
+	if err != nil {
+		event.Error().Err(err).Msg("build session teardown request failed")
+		return
+	}
+	resp, err := p.doWithDeadline(upstreamReq)
+	if err != nil {
+		event.Error().Err(err).Msg("end upstream session failed")
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if err := resp.Body.Close(); err != nil {
+		event.Error().Err(err).Msg("close upstream response body failed")
+	}
+
+	event.Info().Int("status", resp.StatusCode).Msg("ended upstream session")
+}
@@ -0,0 +1,158 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sessionUpstream issues "upstream-1" on initialize and records the session
+// header of every later request.
+type sessionUpstream struct {
+	mu      sync.Mutex
+	seen    []string
+	deletes []string
+}
+
+func (u *sessionUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	sessionID := r.Header.Get(headerMCPSessionID)
+	switch {
+	case r.Method == http.MethodDelete:
+		u.deletes = append(u.deletes, sessionID)
+		w.WriteHeader(http.StatusOK)
+	case sessionID == "":
+		w.Header().Set(headerMCPSessionID, "upstream-1")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	default:
+		u.seen = append(u.seen, sessionID)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (u *sessionUpstream) deleted() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.deletes...)
+}
+
+func TestProxyTranslatesSessionIDs(t *testing.T) {
+	upstreamHandler := &sessionUpstream{}
+	upstream := httptest.NewServer(upstreamHandler)
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SessionTranslate = true
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	post := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{"jsonrpc":"2.0"}`))
+		if sessionID != "" {
+			req.Header.Set(headerMCPSessionID, sessionID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	initRec := post("")
+	clientID := initRec.Header().Get(headerMCPSessionID)
+	if clientID == "" || clientID == "upstream-1" {
+		t.Fatalf("expected a translated session id, got %q", clientID)
+	}
+
+	if rec := post(clientID); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status for follow-up: %d", rec.Code)
+	}
+	upstreamHandler.mu.Lock()
+	seen := append([]string(nil), upstreamHandler.seen...)
+	upstreamHandler.mu.Unlock()
+	if len(seen) != 1 || seen[0] != "upstream-1" {
+		t.Fatalf("upstream saw unexpected session ids: %v", seen)
+	}
+
+	if rec := post("forged"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", rec.Code)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "http://proxy/mcp", nil)
+	del.Header.Set(headerMCPSessionID, clientID)
+	handler.ServeHTTP(httptest.NewRecorder(), del)
+	if got := upstreamHandler.deleted(); len(got) != 1 || got[0] != "upstream-1" {
+		t.Fatalf("unexpected upstream deletes: %v", got)
+	}
+
+	if rec := post(clientID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after session teardown, got %d", rec.Code)
+	}
+}
+
+func TestProxyShutdownEndsSessions(t *testing.T) {
+	upstreamHandler := &sessionUpstream{}
+	upstream := httptest.NewServer(upstreamHandler)
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader(`{}`)))
+	if got := rec.Header().Get(headerMCPSessionID); got != "upstream-1" {
+		t.Fatalf("expected upstream session id to pass through, got %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p.Shutdown(ctx)
+
+	if got := upstreamHandler.deleted(); len(got) != 1 || got[0] != "upstream-1" {
+		t.Fatalf("unexpected upstream deletes: %v", got)
+	}
+}
+
+func TestProxyEndsSessionsAfterClientDisconnects(t *testing.T) {
+	upstreamHandler := &sessionUpstream{}
+	upstream := httptest.NewServer(upstreamHandler)
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.SessionGracePeriod = 50 * time.Millisecond
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	front := httptest.NewUnstartedServer(handler)
+	front.Config.ConnState = handler.(*Proxy).ConnState
+	front.Start()
+	defer front.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Post(front.URL+"/mcp", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	client.CloseIdleConnections()
+
+	waitUntil(t, 2*time.Second, func() bool {
+		return len(upstreamHandler.deleted()) == 1
+	})
+}
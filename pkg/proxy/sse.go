@@ -0,0 +1,101 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+// sseEvent is a single Server-Sent Events frame decoded from an upstream
+// text/event-stream body.
+type sseEvent struct {
+	// ID carries the optional event id used for resumption.
+	ID string
+	// Event is the optional event type; empty means the default "message".
+	Event string
+	// Data joins all data lines of the frame with newlines.
+	Data string
+	// Raw preserves the exact bytes of the frame, including the terminating
+	// blank line, so relays can forward it untouched.
+	Raw []byte
+}
+
+// isMessage reports whether the event carries a JSON-RPC payload.
+func (e *sseEvent) isMessage() bool {
+	return (e.Event == "" || e.Event == "message") && e.Data != ""
+}
+
+// setData replaces the event payload and re-encodes Raw from the id, event
+// and data fields. Comments and other fields of the original frame are dropped.
+func (e *sseEvent) setData(data string) {
+	var raw bytes.Buffer
+	if e.ID != "" {
+		raw.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		raw.WriteString("event: " + e.Event + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		raw.WriteString("data: " + line + "\n")
+	}
+	raw.WriteString("\n")
+	e.Data = data
+	e.Raw = raw.Bytes()
+}
+
+// readSSEEvent consumes lines from r until a complete event has been read.
+// Comment-only frames are returned with empty fields so callers can still
+// relay heartbeats. io.EOF is returned once the stream ends cleanly.
+func readSSEEvent(r *bufio.Reader) (*sseEvent, error) {
+	var (
+		raw  bytes.Buffer
+		data []string
+		evt  sseEvent
+	)
+
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			raw.WriteString(line)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && raw.Len() > 0 {
+				// Treat a trailing frame without a blank line as complete.
+				err = nil
+			} else {
+				return nil, err
+			}
+		}
+
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "" {
+			if raw.Len() == 0 {
+				// Tolerate stray blank lines between frames.
+				continue
+			}
+			evt.Data = strings.Join(data, "\n")
+			evt.Raw = raw.Bytes()
+			return &evt, nil
+		}
+
+		if strings.HasPrefix(trimmed, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(trimmed, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			evt.ID = value
+		case "event":
+			evt.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
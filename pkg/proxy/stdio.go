@@ -0,0 +1,286 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// StdioBridge relays newline-delimited JSON-RPC messages between an MCP client
+// speaking the stdio transport and the signed upstream HTTP endpoint.
+type StdioBridge struct {
+	// proxy supplies the signing and forwarding path shared with HTTP mode.
+	proxy *Proxy
+	// path is the local MCP endpoint path each message is posted to.
+	path string
+	// logger emits structured logs to stderr so stdout stays protocol-clean.
+	logger zerolog.Logger
+
+	// writeMu serialises writes so concurrent responses never interleave.
+	writeMu sync.Mutex
+	// sessionMu guards sessionID.
+	sessionMu sync.Mutex
+	// sessionID echoes the upstream Mcp-Session-Id on follow-up requests.
+	sessionID string
+}
+
+// NewStdioBridge constructs a bridge that forwards stdio messages using the
+// same signing configuration as the HTTP proxy.
+func NewStdioBridge(cfg config.Config) (*StdioBridge, error) {
+	p, err := newProxy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	path := cfg.StdioPath
+	if path == "" {
+		path = "/mcp"
+	}
+
+	return &StdioBridge{
+		proxy:  p,
+		path:   path,
+		logger: p.logger.With().Str("transport", config.TransportStdio).Logger(),
+	}, nil
+}
+
+// Serve reads messages from in until EOF or context cancellation, forwarding
+// each one upstream and writing every JSON-RPC response to out as a single
+// line. Messages are handled concurrently so long-running tool calls do not
+// block cancellations or pings.
+func (b *StdioBridge) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if msg := bytes.TrimSpace(line); len(msg) > 0 {
+				select {
+				case lines <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	b.logger.Info().Str("path", b.path).Msg("stdio bridge started")
+
+	// End the upstream session once every in-flight message has finished; the
+	// serve context is usually cancelled by then.
+	defer func() {
+		timeout := b.proxy.cfg.GracefulShutdownTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		b.proxy.Shutdown(shutdownCtx)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.logger.Info().Msg("stdio bridge stopped")
+			return nil
+		case msg := <-lines:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.handleMessage(ctx, msg, out)
+			}()
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				b.logger.Info().Msg("stdin closed; stdio bridge stopping")
+				return nil
+			}
+			return fmt.Errorf("read stdin: %w", err)
+		}
+	}
+}
+
+// handleMessage forwards a single JSON-RPC message and relays the response.
+func (b *StdioBridge) handleMessage(ctx context.Context, msg []byte, out io.Writer) {
+	var envelope rpcEnvelope
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		// Batches decode into an array and carry no top-level id; forward them as is.
+		envelope = rpcEnvelope{}
+	}
+
+	// Each message gets its own request id, forwarded upstream like on HTTP.
+	reqID := newRequestID()
+	ctx = context.WithValue(ctx, requestIDKey{}, reqID)
+	event := withRPCRequest(b.logger.With().Str("request_id", reqID), msg).Logger()
+
+	ctx, span := b.proxy.tracer.Start(ctx, rpcMethodLabel(msg),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(rpcSpanAttributes(msg)...),
+	)
+	defer span.End()
+
+	if reply, denied := checkAmbiguousRPC(msg, reqID, event); denied {
+		b.writeLine(reply, out, event)
+		return
+	}
+	if reply, denied := b.proxy.checkToolPolicy(msg, reqID, event); denied {
+		if len(reply) > 0 {
+			b.writeLine(reply, out, event)
+		}
+		return
+	}
+	toolLists := toolListIDs(msg)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.path, bytes.NewReader(msg))
+	if err != nil {
+		event.Error().Err(err).Msg("build stdio request failed")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID := b.session(); sessionID != "" {
+		req.Header.Set(headerMCPSessionID, sessionID)
+	}
+	// MCP_STDIO_PATH may point into a route.
+	req, _ = b.proxy.withUpstream(req)
+
+	if a := b.proxy.aggregator; a != nil && b.path == a.path {
+		sessions, _ := a.session(b.session())
+		reply, issued := b.proxy.aggregate(req, sessions, msg, event)
+		if issued != "" {
+			b.setSession(issued)
+		}
+		if len(reply) > 0 {
+			warnRPCErrors(event, reply)
+			b.writeLine(reply, out, event)
+		}
+		return
+	}
+
+	resp, err := b.proxy.forwardRequest(req, event)
+	if err != nil {
+		event.Error().Err(err).Msg("stdio request failed")
+		b.replyError(envelope, reqID, fmt.Sprintf("upstream request failed: %v", err), out, event)
+		return
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			event.Error().Err(closeErr).Msg("close upstream response body failed")
+		}
+	}()
+
+	if sessionID := resp.Header.Get(headerMCPSessionID); sessionID != "" {
+		b.setSession(sessionID)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		const maxLogBody = 64 * 1024
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, maxLogBody))
+		logRPCErrors(event.Warn(), rpcErrorCodes(payload)).
+			Int("status", resp.StatusCode).
+			Bytes("upstream_body", payload).
+			Msg("upstream returned error")
+		if json.Valid(payload) && bytes.Contains(payload, []byte(`"jsonrpc"`)) {
+			b.writeLine(payload, out, event)
+			return
+		}
+		b.replyError(envelope, reqID, fmt.Sprintf("upstream returned %s", resp.Status), out, event)
+		return
+	}
+
+	if isEventStreamResponse(resp) {
+		markStreaming(resp, b.proxy.cfg.StreamIdleTimeout)
+		reader := bufio.NewReader(resp.Body)
+		for {
+			sse, err := readSSEEvent(reader)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					event.Error().Err(err).Msg("read upstream event stream failed")
+				}
+				return
+			}
+			if sse.isMessage() {
+				data, _ := b.proxy.filterToolList([]byte(sse.Data), toolLists)
+				warnRPCErrors(event, data)
+				b.writeLine(data, out, event)
+			}
+		}
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		event.Error().Err(err).Msg("read upstream response failed")
+		b.replyError(envelope, reqID, "failed to read upstream response", out, event)
+		return
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		// Notifications and responses are acknowledged with 202 and no body.
+		return
+	}
+	payload, _ = b.proxy.filterToolList(payload, toolLists)
+	warnRPCErrors(event, payload)
+	b.writeLine(payload, out, event)
+}
+
+// replyError emits a JSON-RPC error when the failed message expected a reply.
+func (b *StdioBridge) replyError(envelope rpcEnvelope, requestID, message string, out io.Writer, event zerolog.Logger) {
+	if !envelope.isRequest() {
+		return
+	}
+	b.writeLine(newRPCError(envelope.ID, rpcCodeInternalError, message, requestID), out, event)
+}
+
+// writeLine compacts payload onto a single line and writes it atomically.
+func (b *StdioBridge) writeLine(payload []byte, out io.Writer, event zerolog.Logger) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, payload); err != nil {
+		event.Error().Err(err).Msg("upstream returned invalid JSON; dropping message")
+		return
+	}
+	buf.WriteByte('\n')
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		event.Error().Err(err).Msg("write stdout failed")
+	}
+}
+
+// session returns the upstream session id captured so far.
+func (b *StdioBridge) session() string {
+	b.sessionMu.Lock()
+	defer b.sessionMu.Unlock()
+	return b.sessionID
+}
+
+// setSession records the upstream session id for subsequent requests.
+func (b *StdioBridge) setSession(id string) {
+	b.sessionMu.Lock()
+	defer b.sessionMu.Unlock()
+	b.sessionID = id
+}
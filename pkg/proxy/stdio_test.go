@@ -0,0 +1,213 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/auth"
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+func TestStdioBridgeRelaysMessages(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:       upstreamURL,
+		APIKey:         "key-id",
+		APISecret:      "secret-value",
+		RequestTimeout: time.Second,
+		Transport:      config.TransportStdio,
+		StdioPath:      "/mcp",
+	}
+
+	bridge, err := NewStdioBridge(cfg)
+	if err != nil {
+		t.Fatalf("create bridge: %v", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		sessions []string
+	)
+	bridge.proxy.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/mcp" {
+			return nil, errors.New("unexpected upstream path " + req.URL.Path)
+		}
+		if req.Header.Get(auth.HeaderSignature) == "" {
+			return nil, errors.New("request was not signed")
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		sessions = append(sessions, req.Header.Get(headerMCPSessionID))
+		mu.Unlock()
+
+		header := make(http.Header)
+		switch {
+		case strings.Contains(string(body), `"initialize"`):
+			header.Set("Content-Type", "application/json")
+			header.Set(headerMCPSessionID, "upstream-session")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader("{\n  \"jsonrpc\": \"2.0\",\n  \"id\": 1,\n  \"result\": {}\n}")),
+			}, nil
+		case strings.Contains(string(body), `"tools/call"`):
+			header.Set("Content-Type", "text/event-stream")
+			stream := "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n" +
+				": heartbeat\n\n" +
+				"data: {\"jsonrpc\":\"2.0\",\"id\":2,\"result\":{}}\n\n"
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(stream)),
+			}, nil
+		default:
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader("")),
+			}, nil
+		}
+	})
+
+	in, inWriter := io.Pipe()
+	out := &syncBuffer{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bridge.Serve(context.Background(), in, out)
+	}()
+
+	writeLine := func(line string) {
+		t.Helper()
+		if _, err := io.WriteString(inWriter, line+"\n"); err != nil {
+			t.Fatalf("write stdin: %v", err)
+		}
+	}
+
+	writeLine(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	waitUntil(t, time.Second, func() bool { return out.lines() == 1 })
+
+	writeLine(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	writeLine(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo"}}`)
+	waitUntil(t, time.Second, func() bool { return out.lines() == 3 })
+
+	if err := inWriter.Close(); err != nil {
+		t.Fatalf("close stdin: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("bridge did not stop after stdin closed")
+	}
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got[0] != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Fatalf("unexpected initialize response: %s", got[0])
+	}
+	if got[1] != `{"jsonrpc":"2.0","method":"notifications/progress"}` {
+		t.Fatalf("unexpected streamed notification: %s", got[1])
+	}
+	if got[2] != `{"jsonrpc":"2.0","id":2,"result":{}}` {
+		t.Fatalf("unexpected streamed response: %s", got[2])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sessions[0] != "" {
+		t.Fatalf("initialize should not carry a session id, got %q", sessions[0])
+	}
+	for _, sessionID := range sessions[1:] {
+		if sessionID != "upstream-session" {
+			t.Fatalf("expected session id to be echoed, got %q", sessionID)
+		}
+	}
+}
+
+func TestStdioBridgeReportsUpstreamFailures(t *testing.T) {
+	upstreamURL, err := url.Parse("https://upstream.example.com")
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	cfg := config.Config{
+		Upstream:       upstreamURL,
+		APIKey:         "key-id",
+		APISecret:      "secret-value",
+		RequestTimeout: time.Second,
+	}
+
+	bridge, err := NewStdioBridge(cfg)
+	if err != nil {
+		t.Fatalf("create bridge: %v", err)
+	}
+	requestIDs := make(chan string, 1)
+	bridge.proxy.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if body, _ := io.ReadAll(req.Body); strings.Contains(string(body), `"id":"abc"`) {
+			requestIDs <- req.Header.Get(headerRequestID)
+		}
+		return &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Status:     "502 Bad Gateway",
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("gateway down")),
+		}, nil
+	})
+
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":"abc","method":"tools/list"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n")
+	out := &syncBuffer{}
+
+	if err := bridge.Serve(context.Background(), in, out); err != nil {
+		t.Fatalf("serve returned error: %v", err)
+	}
+
+	want := `{"jsonrpc":"2.0","id":"abc","error":{"code":-32603,"message":"upstream returned 502 Bad Gateway",` +
+		`"data":{"request_id":"` + <-requestIDs + `"}}}` + "\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected output:\n got %s\nwant %s", got, want)
+	}
+}
+
+// syncBuffer is a goroutine-safe bytes.Buffer for capturing bridge output.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) lines() int {
+	return strings.Count(b.String(), "\n")
+}
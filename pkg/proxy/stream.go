@@ -0,0 +1,300 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/metrics"
+)
+
+// errRequestTimeout is the cancellation cause recorded when an upstream
+// request or stream outlives its deadline.
+var errRequestTimeout = errors.New("upstream request timed out")
+
+// deadlineBody wraps an upstream response body whose request context is
+// cancelled by timer. By default the timer enforces the total request
+// deadline; stream replaces it with an optional idle deadline for event
+// streams.
+type deadlineBody struct {
+	io.ReadCloser
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+	// idle is re-armed after every successful read once streaming; zero
+	// leaves the stream open indefinitely.
+	idle time.Duration
+}
+
+// stream lifts the total deadline. When idle is positive the body is instead
+// cancelled after idle passes without any data from the upstream.
+func (b *deadlineBody) stream(idle time.Duration) {
+	b.idle = idle
+	if idle > 0 {
+		b.timer.Reset(idle)
+		return
+	}
+	b.timer.Stop()
+}
+
+// Read forwards to the upstream body and pushes the idle deadline back
+// whenever data arrives.
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.idle > 0 && n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	return n, err
+}
+
+// Close stops the timer and releases the request context.
+func (b *deadlineBody) Close() error {
+	b.timer.Stop()
+	b.cancel(nil)
+	return b.ReadCloser.Close()
+}
+
+// markStreaming swaps the total request deadline on resp, when it carries
+// one, for the given idle deadline.
+func markStreaming(resp *http.Response, idle time.Duration) {
+	if body, ok := resp.Body.(*deadlineBody); ok {
+		body.stream(idle)
+	}
+}
+
+// clearWriteDeadline removes the listener's write timeout for the current
+// response so long-lived streams are not cut off by ServerWriteTimeout.
+func clearWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// relaySSE copies events from body to w one at a time, flushing after each
+// so clients observe progress as it happens. onEvent, when non-nil, sees each
+// event before it is written. It returns the number of events relayed and the
+// error that ended the stream; io.EOF marks a clean end.
+func relaySSE(w io.Writer, flusher http.Flusher, body io.Reader, onEvent func(*sseEvent)) (int, error) {
+	events := 0
+	reader := bufio.NewReader(body)
+	for {
+		sse, err := readSSEEvent(reader)
+		if err != nil {
+			return events, err
+		}
+		if onEvent != nil {
+			onEvent(sse)
+		}
+		if _, err := w.Write(sse.Raw); err != nil {
+			return events, fmt.Errorf("write event: %w", err)
+		}
+		flusher.Flush()
+		events++
+	}
+}
+
+// writeFrames writes previously recorded SSE frames to w.
+func writeFrames(w io.Writer, frames [][]byte) error {
+	for _, frame := range frames {
+		if _, err := w.Write(frame); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+	}
+	return nil
+}
+
+// resumeEventStream looks up the events a reconnecting client missed since
+// its Last-Event-ID. When the proxy can replay them itself, the header is
+// dropped from the returned request so the upstream does not replay the same
+// events again.
+func (p *Proxy) resumeEventStream(r *http.Request, event zerolog.Logger) (*http.Request, [][]byte) {
+	lastID := r.Header.Get(headerLastEventID)
+	if lastID == "" {
+		return r, nil
+	}
+	replay := p.upstreamFor(r.Context()).sessions.replay(r.Header.Get(headerMCPSessionID))
+	if replay == nil {
+		return r, nil
+	}
+	missed, ok := replay.since(lastID)
+	if !ok {
+		event.Debug().Str("last_event_id", lastID).Msg("last event id not buffered; deferring to upstream")
+		return r, nil
+	}
+
+	event.Info().
+		Str("last_event_id", lastID).
+		Int("replayed", len(missed)).
+		Msg("resuming event stream")
+	clone := r.Clone(r.Context())
+	clone.Header.Del(headerLastEventID)
+	return clone, missed
+}
+
+// isEventStreamResponse reports whether the upstream answered with SSE.
+func isEventStreamResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// relayEventStream forwards the signed GET to the upstream and relays its
+// text/event-stream response event by event. It falls back to the local
+// heartbeat stream only when the upstream answers 404 or 405, which the
+// Streamable HTTP transport uses to signal that it offers no GET stream.
+// Frames in missed are replayed before live events.
+func (p *Proxy) relayEventStream(w http.ResponseWriter, r *http.Request, event zerolog.Logger, start time.Time, missed [][]byte) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeLocalError(w, r, "streaming unsupported", http.StatusInternalServerError)
+		event.Error().Msg("response writer does not support flushing for SSE")
+		return
+	}
+
+	resp, err := p.forwardRequest(r, event)
+	if errors.Is(err, errCircuitOpen) {
+		p.writeCircuitOpen(w, r, nil, event, start)
+		return
+	}
+	if err != nil {
+		p.writeForwardError(w, r, err, event, start)
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		if err := resp.Body.Close(); err != nil {
+			event.Error().Err(err).Msg("close upstream response body failed")
+		}
+		event.Info().
+			Int("status", resp.StatusCode).
+			Msg("upstream offers no event stream; serving local heartbeat")
+		p.serveEventStream(w, r, event, missed)
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK || !isEventStreamResponse(resp) {
+		p.writeResponse(w, r, resp, event, start)
+		return
+	}
+
+	// Server-initiated streams may legitimately stay quiet for a long time, so
+	// only the total deadline is lifted here; no idle deadline applies.
+	markStreaming(resp, 0)
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			event.Error().Err(err).Msg("close upstream event stream failed")
+		}
+	}()
+
+	cleanHopHeaders(resp.Header)
+	// Events may be renumbered or rewritten on the way through.
+	resp.Header.Del("Content-Length")
+	copyResponseHeaders(w.Header(), resp.Header)
+	clearWriteDeadline(w)
+	w.WriteHeader(resp.StatusCode)
+	flusher.Flush()
+
+	event.Info().Msg("relaying upstream event stream")
+	defer p.metrics.StreamOpened(metrics.StreamUpstream)()
+
+	if err := writeFrames(w, missed); err != nil {
+		event.Error().Err(err).Msg("failed to replay missed events")
+		return
+	}
+	flusher.Flush()
+
+	var onEvent func(*sseEvent)
+	if replay := p.upstreamFor(r.Context()).sessions.replay(r.Header.Get(headerMCPSessionID)); replay != nil {
+		onEvent = replay.newStream().record
+	}
+	events, err := relaySSE(w, flusher, resp.Body, onEvent)
+	switch {
+	case errors.Is(err, io.EOF):
+		event.Info().Int("events", events).Dur("duration", time.Since(start)).Msg("upstream event stream ended")
+	case r.Context().Err() != nil:
+		event.Info().Int("events", events).Dur("duration", time.Since(start)).Msg("event stream closed")
+	default:
+		event.Error().Err(err).Int("events", events).Msg("relay upstream event stream failed")
+	}
+}
+
+// writeEventStream relays a text/event-stream answer to a POST event by
+// event. The total request deadline is replaced by the upstream's
+// StreamIdleTimeout so long tool calls that keep reporting progress are not
+// cut off.
+func (p *Proxy) writeEventStream(w http.ResponseWriter, r *http.Request, resp *http.Response, event zerolog.Logger, start time.Time) {
+	markStreaming(resp, p.upstreamFor(r.Context()).cfg.StreamIdleTimeout)
+
+	cleanHopHeaders(resp.Header)
+	// Events may be renumbered or rewritten on the way through.
+	resp.Header.Del("Content-Length")
+	copyResponseHeaders(w.Header(), resp.Header)
+	clearWriteDeadline(w)
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		// Without flushing the client still receives every event, just not
+		// incrementally.
+		flusher = noopFlusher{}
+	}
+	flusher.Flush()
+	defer p.metrics.StreamOpened(metrics.StreamResponse)()
+
+	// Record progress as a stream of the client's session so it can be
+	// replayed on a GET with Last-Event-ID if this response is cut short.
+	clientID := r.Header.Get(headerMCPSessionID)
+	if clientID == "" {
+		clientID = resp.Header.Get(headerMCPSessionID)
+	}
+	var replay *replayStream
+	if buf := p.upstreamFor(r.Context()).sessions.replay(clientID); buf != nil {
+		replay = buf.newStream()
+	}
+	toolLists := toolListFilter(r.Context())
+	var codes []int
+	events, err := relaySSE(w, flusher, resp.Body, func(sse *sseEvent) {
+		if toolLists != nil && sse.isMessage() {
+			if filtered, ok := p.filterToolList([]byte(sse.Data), toolLists); ok {
+				sse.setData(string(filtered))
+			}
+		}
+		if replay != nil {
+			replay.record(sse)
+		}
+		if sse.isMessage() {
+			codes = append(codes, rpcErrorCodes([]byte(sse.Data))...)
+		}
+	})
+	switch {
+	case errors.Is(err, io.EOF):
+		logRPCErrors(event.Info(), codes).
+			Int("events", events).
+			Dur("duration", time.Since(start)).
+			Msg("request proxied")
+	case r.Context().Err() != nil:
+		event.Info().
+			Int("events", events).
+			Dur("duration", time.Since(start)).
+			Msg("client closed response stream")
+	default:
+		event.Error().
+			Err(err).
+			Int("events", events).
+			Dur("duration", time.Since(start)).
+			Msg("stream response failed")
+	}
+}
+
+// noopFlusher stands in for writers that cannot flush.
+type noopFlusher struct{}
+
+// Flush implements http.Flusher.
+func (noopFlusher) Flush() {}
@@ -0,0 +1,274 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+func newStreamTestConfig(t *testing.T, upstream string) config.Config {
+	t.Helper()
+
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatalf("parse upstream url: %v", err)
+	}
+
+	return config.Config{
+		ListenAddr:              "127.0.0.1:0",
+		Upstream:                upstreamURL,
+		APIKey:                  "key-id",
+		APISecret:               "secret-value",
+		RequestTimeout:          200 * time.Millisecond,
+		LogLevel:                "info",
+		SSEMode:                 config.SSEModeUpstream,
+		ServerReadTimeout:       time.Second,
+		ServerWriteTimeout:      time.Second,
+		ServerIdleTimeout:       time.Second,
+		GracefulShutdownTimeout: time.Second,
+	}
+}
+
+func TestProxyRelaysUpstreamEventStream(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("x-signature") == "" {
+			http.Error(w, "unsigned", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+
+		fmt.Fprint(w, "id: 1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/tools/list_changed\"}\n\n")
+		flusher.Flush()
+
+		// Hold the second event past the request timeout to prove the
+		// stream is not cut by the total deadline.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		flusher.Flush()
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	front := httptest.NewServer(handler)
+	defer front.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, front.URL+"/mcp", nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", got)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := readSSEEvent(reader)
+	if err != nil {
+		t.Fatalf("read first event: %v", err)
+	}
+	if first.ID != "1" || !strings.Contains(first.Data, "list_changed") {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	close(release)
+
+	second, err := readSSEEvent(reader)
+	if err != nil {
+		t.Fatalf("read second event: %v", err)
+	}
+	if second.ID != "2" || !strings.Contains(second.Data, "progress") {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+}
+
+func TestProxyEventStreamFallsBackWhenUpstreamHasNone(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil).WithContext(ctx)
+	rec := newFlushRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	waitUntil(t, time.Second, func() bool {
+		return strings.Contains(rec.String(), ":ok")
+	})
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("event stream handler did not exit after context cancel")
+	}
+}
+
+func TestProxyEventStreamPropagatesUpstreamErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	rec := newFlushRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://proxy/mcp", nil))
+
+	if rec.status != http.StatusForbidden {
+		t.Fatalf("unexpected status: %d", rec.status)
+	}
+	if !strings.Contains(rec.String(), "forbidden") {
+		t.Fatalf("unexpected body: %q", rec.String())
+	}
+}
+
+func TestProxyStreamsPostResponsesIncrementally(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		flusher.Flush()
+
+		// The final result only arrives once the client has seen progress,
+		// and after the total request timeout has elapsed.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n")
+		flusher.Flush()
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.StreamIdleTimeout = time.Second
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	front := httptest.NewServer(handler)
+	defer front.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, front.URL+"/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`))
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := readSSEEvent(reader)
+	if err != nil {
+		t.Fatalf("read progress event: %v", err)
+	}
+	if !strings.Contains(first.Data, "notifications/progress") {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	close(release)
+
+	second, err := readSSEEvent(reader)
+	if err != nil {
+		t.Fatalf("read result event: %v", err)
+	}
+	if !strings.Contains(second.Data, `"result"`) {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+}
+
+func TestProxyEndsIdlePostStreams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	cfg := newStreamTestConfig(t, upstream.URL)
+	cfg.RequestTimeout = 5 * time.Second
+	cfg.StreamIdleTimeout = 100 * time.Millisecond
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	rec := newFlushRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://proxy/mcp", strings.NewReader("{}")))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle stream was not closed")
+	}
+	if !strings.Contains(rec.String(), "notifications/progress") {
+		t.Fatalf("expected relayed event, got %q", rec.String())
+	}
+}
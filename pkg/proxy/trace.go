@@ -0,0 +1,117 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/tracing"
+)
+
+// MCP-specific span attributes; OpenTelemetry has no convention for them yet.
+const (
+	attrMCPTool        = attribute.Key("mcp.tool.name")
+	attrMCPResourceURI = attribute.Key("mcp.resource.uri")
+	attrMCPBatchSize   = attribute.Key("mcp.batch.size")
+	attrMCPMethods     = attribute.Key("mcp.batch.methods")
+	attrMCPTools       = attribute.Key("mcp.batch.tools")
+)
+
+// startServerSpan continues any trace context sent by the client and starts
+// the span covering the whole request.
+func (p *Proxy) startServerSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := p.tracer.Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// endSpan records the HTTP status on span and marks server errors.
+func endSpan(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// startClientSpan starts the span covering one upstream round trip. The
+// returned request carries the span so newUpstreamRequest propagates it.
+func (p *Proxy) startClientSpan(r *http.Request) (*http.Request, trace.Span) {
+	u := p.upstreamFor(r.Context())
+	target := p.targetFor(r.Context())
+	ctx, span := p.tracer.Start(r.Context(), r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLFull(u.singleJoiningURL(target.baseURL, r.URL).String()),
+			semconv.ServerAddress(target.baseURL.Hostname()),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// failSpan records a round trip that produced no response.
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
+
+// injectTraceContext writes the current trace context to the upstream
+// headers, replacing whatever the client sent.
+func injectTraceContext(r *http.Request, header http.Header) {
+	tracing.Propagator.Inject(r.Context(), propagation.HeaderCarrier(header))
+}
+
+// rpcSpanAttributes describes the JSON-RPC method, id and tool of payload.
+func rpcSpanAttributes(payload []byte) []attribute.KeyValue {
+	msgs, batch := decodeRPC(payload)
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("jsonrpc")}
+	if !batch {
+		msg := msgs[0]
+		if msg.Method != "" {
+			attrs = append(attrs, semconv.RPCMethod(msg.Method))
+		}
+		if len(msg.ID) > 0 {
+			attrs = append(attrs, semconv.RPCJsonrpcRequestID(string(msg.ID)))
+		}
+		tool, uri := msg.target()
+		if tool != "" {
+			attrs = append(attrs, attrMCPTool.String(tool))
+		}
+		if uri != "" {
+			attrs = append(attrs, attrMCPResourceURI.String(uri))
+		}
+		return attrs
+	}
+
+	var methods, tools []string
+	for i := range msgs {
+		methods = append(methods, msgs[i].Method)
+		if tool, _ := msgs[i].target(); tool != "" {
+			tools = append(tools, tool)
+		}
+	}
+	attrs = append(attrs, attrMCPBatchSize.Int(len(msgs)), attrMCPMethods.StringSlice(methods))
+	if len(tools) > 0 {
+		attrs = append(attrs, attrMCPTools.StringSlice(tools))
+	}
+	return attrs
+}
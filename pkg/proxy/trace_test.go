@@ -0,0 +1,117 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/tracing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestProxyTracesRequests(t *testing.T) {
+	traceParents := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParents <- r.Header.Get("Traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":7,"result":{}}`)
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+	p := handler.(*Proxy)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	p.tracing = tracing.NewWithProvider(provider, provider.Shutdown)
+	p.tracer = p.tracing.Tracer()
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search"}}`))
+	req.Header.Set("Traceparent", testTraceParent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected server and client spans, got %d", len(spans))
+	}
+	client, server := spans[0], spans[1]
+	if server.SpanKind != trace.SpanKindServer || client.SpanKind != trace.SpanKindClient {
+		t.Fatalf("unexpected span kinds %v, %v", server.SpanKind, client.SpanKind)
+	}
+
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("server span did not continue the client trace: %s", got)
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatal("client span is not a child of the server span")
+	}
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + client.SpanContext.SpanID().String() + "-01"
+	if got := <-traceParents; got != want {
+		t.Fatalf("upstream traceparent = %q, want %q", got, want)
+	}
+
+	for key, want := range map[attribute.Key]string{
+		"rpc.method":             "tools/call",
+		"rpc.jsonrpc.request_id": "7",
+		attrMCPTool:              "search",
+	} {
+		if got := spanAttribute(server, key).AsString(); got != want {
+			t.Errorf("server span %s = %q, want %q", key, got, want)
+		}
+	}
+	if got := spanAttribute(client, "url.full").AsString(); got != upstream.URL+"/mcp" {
+		t.Errorf("client span url.full = %q", got)
+	}
+	for _, span := range spans {
+		if got := spanAttribute(span, "http.response.status_code").AsInt64(); got != http.StatusOK {
+			t.Errorf("%s span status = %d", span.SpanKind, got)
+		}
+	}
+}
+
+func TestProxyPropagatesTraceContextWithoutExporter(t *testing.T) {
+	traceParents := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParents <- r.Header.Get("Traceparent")
+	}))
+	defer upstream.Close()
+
+	handler, err := New(newStreamTestConfig(t, upstream.URL))
+	if err != nil {
+		t.Fatalf("create proxy: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.Header.Set("Traceparent", testTraceParent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := <-traceParents; got != testTraceParent {
+		t.Fatalf("upstream traceparent = %q, want %q", got, testTraceParent)
+	}
+}
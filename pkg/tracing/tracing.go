@@ -0,0 +1,80 @@
+// Copyright © 2025 Prabhjot Singh Sethi, All Rights reserved
+// Author: Prabhjot Singh Sethi <prabhjot.sethi@gmail.com>
+
+// Package tracing configures OpenTelemetry tracing for the proxy. Spans are
+// exported over OTLP/HTTP when an endpoint is configured; otherwise a no-op
+// provider is used, which still carries incoming W3C trace context through
+// to the upstream.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/go-core-stack/mcp-auth-proxy/pkg/config"
+)
+
+// InstrumentationName identifies the proxy's tracer.
+const InstrumentationName = "github.com/go-core-stack/mcp-auth-proxy"
+
+// Propagator reads and writes W3C traceparent, tracestate and baggage headers.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Provider owns the tracer provider and flushes it on shutdown.
+type Provider struct {
+	provider trace.TracerProvider
+	shutdown func(context.Context) error
+}
+
+// New builds an OTLP/HTTP exporting provider when cfg.OTLPEndpoint is set and
+// a no-op provider otherwise.
+func New(cfg config.Config) (*Provider, error) {
+	if cfg.OTLPEndpoint == "" {
+		return NewWithProvider(noop.NewTracerProvider(), nil), nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("build trace resource: %w", err)
+	}
+
+	sdkProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	return NewWithProvider(sdkProvider, sdkProvider.Shutdown), nil
+}
+
+// NewWithProvider wraps an existing tracer provider; shutdown may be nil.
+func NewWithProvider(provider trace.TracerProvider, shutdown func(context.Context) error) *Provider {
+	return &Provider{provider: provider, shutdown: shutdown}
+}
+
+// Tracer returns the proxy's tracer.
+func (p *Provider) Tracer() trace.Tracer {
+	return p.provider.Tracer(InstrumentationName)
+}
+
+// Shutdown flushes buffered spans and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.shutdown == nil {
+		return nil
+	}
+	return p.shutdown(ctx)
+}